@@ -0,0 +1,90 @@
+package raycast
+
+import (
+	"math"
+
+	"raycast.com/tiledgrid"
+)
+
+// textureAnimation is the textures an animated tile cycles through. Cells
+// placed with the same animated tile share its animations, and all of them
+// run off the world's clock, so they stay in step.
+type textureAnimation struct {
+	frames []string
+	// milliseconds each frame is shown for
+	durations []float64
+	total     float64
+}
+
+// frame returns the texture to show at clock milliseconds.
+func (a *textureAnimation) frame(clock float64) string {
+	if a.total <= 0 {
+		return a.frames[0]
+	}
+	t := math.Mod(clock, a.total)
+	for i, d := range a.durations {
+		if t < d {
+			return a.frames[i]
+		}
+		t -= d
+	}
+	return a.frames[len(a.frames)-1]
+}
+
+// the textures of a tile that can animate
+var animatedTextures = []func(*tiledgrid.TileData) string{
+	func(t *tiledgrid.TileData) string { return t.WallTex },
+	func(t *tiledgrid.TileData) string { return t.WallTexN },
+	func(t *tiledgrid.TileData) string { return t.WallTexS },
+	func(t *tiledgrid.TileData) string { return t.WallTexE },
+	func(t *tiledgrid.TileData) string { return t.WallTexW },
+	func(t *tiledgrid.TileData) string { return t.FloorTex },
+	func(t *tiledgrid.TileData) string { return t.CeilingTex },
+	func(t *tiledgrid.TileData) string { return t.DoorTex },
+}
+
+// tileAnimations builds the animations of the tiles placed in a map, once
+// for each animated tile type so cells placed with it share them.
+type tileAnimations map[*tiledgrid.Animation]map[string]*textureAnimation
+
+// of returns the animations of td's textures keyed by the texture they
+// replace, or nil if it doesn't animate. A frame tile with no texture of a
+// kind keeps the animated tile's own texture.
+func (r tileAnimations) of(td *tiledgrid.TileData) map[string]*textureAnimation {
+	if td.Animation == nil || len(td.Animation.Frames) == 0 {
+		return nil
+	}
+	if animations, ok := r[td.Animation]; ok {
+		return animations
+	}
+	var animations map[string]*textureAnimation
+	for _, texture := range animatedTextures {
+		base := texture(td)
+		if base == "" {
+			continue
+		}
+		if _, ok := animations[base]; ok {
+			continue
+		}
+		a := &textureAnimation{}
+		changes := false
+		for _, f := range td.Animation.Frames {
+			name := texture(f.Tile)
+			if name == "" {
+				name = base
+			}
+			changes = changes || name != base
+			a.frames = append(a.frames, name)
+			a.durations = append(a.durations, float64(f.Duration))
+			a.total += float64(f.Duration)
+		}
+		if changes {
+			if animations == nil {
+				animations = map[string]*textureAnimation{}
+			}
+			animations[base] = a
+		}
+	}
+	r[td.Animation] = animations
+	return animations
+}
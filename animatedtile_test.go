@@ -0,0 +1,72 @@
+package raycast
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"raycast.com/tiledgrid"
+)
+
+func TestTileAnimations(t *testing.T) {
+	wall := func(id int, tex string, frames ...int) *tiledgrid.TileConfig {
+		tc := &tiledgrid.TileConfig{Id: id, Properties: tiledgrid.TileData{Block: true, WallTex: tex}.Properties()}
+		for _, frame := range frames {
+			tc.Animation = append(tc.Animation, tiledgrid.TileFrame{TileId: frame, Duration: 100})
+		}
+		return tc
+	}
+	tileSet := tiledgrid.NewTileSet("tiles.json", []*tiledgrid.TileConfig{
+		wall(0, "wall-1"),
+		// two animated tiles with the same texture, flickering to different
+		// textures
+		wall(1, "wall-1", 0, 3),
+		wall(2, "wall-1", 0, 4),
+		wall(3, "wall-2"),
+		wall(4, "wall-3"),
+	}, GridTileSize, 8)
+	grid := &tiledgrid.TiledGrid{
+		Width:  5,
+		Height: 1,
+		Layers: []*tiledgrid.Layer{
+			{Name: "Tile Layer 1", Type: "tilelayer", Width: 5, Height: 1, Data: []int{1, 2, 3, 1, 2}},
+		},
+		TileSetReferences: []*tiledgrid.TileSetReference{{Source: "tiles.json", FirstGid: 1}},
+	}
+	var mapData, tileSetData bytes.Buffer
+	if err := grid.WriteJSON(&mapData); err != nil {
+		t.Fatal(err)
+	}
+	if err := tileSet.WriteJSON(&tileSetData); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"map.json":   {Data: mapData.Bytes()},
+		"tiles.json": {Data: tileSetData.Bytes()},
+	}
+
+	w, err := NewWorldFS(fsys, "map.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clock float64
+		want  [5]string
+	}{
+		{clock: 0, want: [5]string{"wall-1", "wall-1", "wall-1", "wall-1", "wall-1"}},
+		{clock: 150, want: [5]string{"wall-1", "wall-2", "wall-3", "wall-1", "wall-2"}},
+		{clock: 250, want: [5]string{"wall-1", "wall-1", "wall-1", "wall-1", "wall-1"}},
+	}
+	for _, tt := range tests {
+		w.clock = tt.clock
+		for x, want := range tt.want {
+			tile := w.tiles[x][0]
+			if got := w.texture(tile, tile.wallTex); got != want {
+				t.Errorf("at %gms: tile %d shows %s, want %s", tt.clock, x, got, want)
+			}
+		}
+	}
+	if w.tiles[1][0].animations["wall-1"] != w.tiles[4][0].animations["wall-1"] {
+		t.Error("cells placed with the same tile have their own animations")
+	}
+}
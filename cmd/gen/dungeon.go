@@ -0,0 +1,451 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+)
+
+type cell int
+
+const (
+	wallCell cell = iota
+	floorCell
+	doorCell
+	lockedDoorCell
+)
+
+type point struct {
+	x int
+	y int
+}
+
+// room is the open interior of a room. The ring of tiles around it is its
+// wall.
+type room struct {
+	x, y, w, h int
+	parent     int
+	depth      int
+	// door leading into the room from its parent
+	door point
+}
+
+func (r *room) center() point {
+	return point{x: r.x + r.w/2, y: r.y + r.h/2}
+}
+
+func (r *room) contains(p point) bool {
+	return p.x >= r.x && p.x < r.x+r.w && p.y >= r.y && p.y < r.y+r.h
+}
+
+// onWall reports whether p is on the ring around the room, and whether it's
+// one of the ring's corners.
+func (r *room) onWall(p point) (wall bool, corner bool) {
+	left, right := p.x == r.x-1, p.x == r.x+r.w
+	top, bottom := p.y == r.y-1, p.y == r.y+r.h
+	inX := p.x >= r.x-1 && p.x <= r.x+r.w
+	inY := p.y >= r.y-1 && p.y <= r.y+r.h
+	if !inX || !inY {
+		return false, false
+	}
+	return left || right || top || bottom, (left || right) && (top || bottom)
+}
+
+func (r *room) overlaps(o *room, gap int) bool {
+	return r.x-gap < o.x+o.w && o.x-gap < r.x+r.w && r.y-gap < o.y+o.h && o.y-gap < r.y+r.h
+}
+
+type object struct {
+	objectType string
+	name       string
+	pos        point
+}
+
+type dungeon struct {
+	width, height int
+	cells         [][]cell
+	// doors set north sit in a passage running north to south, so they run
+	// east to west across it and block movement along y
+	north   map[point]bool
+	rooms   []*room
+	objects []object
+	start   point
+	end     point
+}
+
+type options struct {
+	width, height int
+	rooms         int
+	locks         int
+	enemies       int
+	enemyNames    []string
+	sceneryNames  []string
+}
+
+const (
+	minRoomSize = 3
+	maxRoomSize = 8
+	// attempts at a full layout before giving up
+	maxLayouts = 100
+	// attempts at placing each room before settling for fewer rooms
+	maxRoomTries = 200
+)
+
+// generate builds a dungeon from the seed. The same seed and options always
+// give the same dungeon.
+func generate(seed int64, opts options) (*dungeon, error) {
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < maxLayouts; i++ {
+		d, ok := layout(rng, opts)
+		if ok && d.solvable(opts.locks > 0) {
+			d.populate(rng, opts)
+			return d, nil
+		}
+	}
+	return nil, errors.New("could not lay out a dungeon with these options, try a bigger map or fewer rooms")
+}
+
+func layout(rng *rand.Rand, opts options) (*dungeon, bool) {
+	d := &dungeon{
+		width:  opts.width,
+		height: opts.height,
+		cells:  make([][]cell, opts.width),
+		north:  map[point]bool{},
+	}
+	for x := range d.cells {
+		d.cells[x] = make([]cell, opts.height)
+	}
+
+	for tries := 0; len(d.rooms) < opts.rooms && tries < maxRoomTries; tries++ {
+		w := minRoomSize + rng.Intn(maxRoomSize-minRoomSize+1)
+		h := minRoomSize + rng.Intn(maxRoomSize-minRoomSize+1)
+		if w+2 >= d.width || h+2 >= d.height {
+			continue
+		}
+		r := &room{
+			x: 1 + rng.Intn(d.width-w-1),
+			y: 1 + rng.Intn(d.height-h-1),
+			w: w,
+			h: h,
+		}
+		fits := true
+		for _, o := range d.rooms {
+			// leave room for a wall on each side and a corridor between
+			if r.overlaps(o, 3) {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			d.rooms = append(d.rooms, r)
+		}
+	}
+	if len(d.rooms) < 2 {
+		return nil, false
+	}
+
+	for _, r := range d.rooms {
+		for x := r.x; x < r.x+r.w; x++ {
+			for y := r.y; y < r.y+r.h; y++ {
+				d.cells[x][y] = floorCell
+			}
+		}
+	}
+
+	// join each room to one placed before it, so the rooms form a tree
+	// rooted at the start room
+	for i := 1; i < len(d.rooms); i++ {
+		r := d.rooms[i]
+		r.parent = rng.Intn(i)
+		r.depth = d.rooms[r.parent].depth + 1
+		if !d.connect(rng, d.rooms[r.parent], r) {
+			return nil, false
+		}
+	}
+
+	d.start = d.rooms[0].center()
+	deepest := d.rooms[1]
+	for _, r := range d.rooms[1:] {
+		if r.depth > deepest.depth {
+			deepest = r
+		}
+	}
+	d.end = deepest.center()
+	d.lock(rng, deepest, opts.locks)
+	return d, true
+}
+
+// connect digs an L shaped corridor from a to b and puts a door wherever it
+// passes through the wall of a room. It fails if the corridor would run
+// along a wall or through a corner.
+func (d *dungeon) connect(rng *rand.Rand, a *room, b *room) bool {
+	from, to := a.center(), b.center()
+	horizontalFirst := rng.Intn(2) == 0
+	for attempt := 0; attempt < 2; attempt++ {
+		path := lPath(from, to, horizontalFirst)
+		if doors, ok := d.doorsOnPath(path); ok {
+			for _, p := range path {
+				if d.cells[p.x][p.y] == wallCell {
+					d.cells[p.x][p.y] = floorCell
+				}
+			}
+			for _, door := range doors {
+				d.cells[door.pos.x][door.pos.y] = doorCell
+				d.north[door.pos] = door.north
+				if b.contains(door.next) {
+					b.door = door.pos
+				}
+			}
+			return true
+		}
+		horizontalFirst = !horizontalFirst
+	}
+	return false
+}
+
+type pathDoor struct {
+	pos   point
+	north bool
+	// the tile the path steps into after the door
+	next point
+}
+
+func (d *dungeon) doorsOnPath(path []point) ([]pathDoor, bool) {
+	var doors []pathDoor
+	for i, p := range path {
+		if p.x <= 0 || p.y <= 0 || p.x >= d.width-1 || p.y >= d.height-1 {
+			return nil, false
+		}
+		for _, r := range d.rooms {
+			wall, corner := r.onWall(p)
+			if !wall {
+				continue
+			}
+			if corner || i == 0 || i == len(path)-1 {
+				return nil, false
+			}
+			prev, next := path[i-1], path[i+1]
+			if prev.x != next.x && prev.y != next.y {
+				// turning inside a wall
+				return nil, false
+			}
+			if w, _ := r.onWall(next); w {
+				// running along a wall
+				return nil, false
+			}
+			if d.cells[p.x][p.y] == floorCell {
+				// another corridor already opened this wall
+				return nil, false
+			}
+			doors = append(doors, pathDoor{pos: p, north: prev.x == next.x, next: next})
+		}
+	}
+	return doors, true
+}
+
+func lPath(from point, to point, horizontalFirst bool) []point {
+	path := []point{from}
+	p := from
+	step := func(target int, v *int) {
+		for *v != target {
+			if *v < target {
+				*v++
+			} else {
+				*v--
+			}
+			path = append(path, p)
+		}
+	}
+	if horizontalFirst {
+		step(to.x, &p.x)
+		step(to.y, &p.y)
+	} else {
+		step(to.y, &p.y)
+		step(to.x, &p.x)
+	}
+	return path
+}
+
+// lock locks up to n doors on the way from the start room to end, and hides
+// a key for each somewhere the player can get to before reaching it.
+func (d *dungeon) lock(rng *rand.Rand, end *room, n int) {
+	var path []*room
+	for r := end; r != d.rooms[0]; r = d.rooms[r.parent] {
+		path = append(path, r)
+	}
+	rng.Shuffle(len(path), func(i, j int) { path[i], path[j] = path[j], path[i] })
+	if n > len(path) {
+		n = len(path)
+	}
+	locked := path[:n]
+	sort.Slice(locked, func(i, j int) bool { return locked[i].depth < locked[j].depth })
+
+	for _, r := range locked {
+		d.cells[r.door.x][r.door.y] = lockedDoorCell
+		var before []*room
+		for _, o := range d.rooms {
+			if !d.below(o, r) {
+				before = append(before, o)
+			}
+		}
+		keyRoom := before[rng.Intn(len(before))]
+		d.objects = append(d.objects, object{objectType: "pickup", name: "key", pos: d.freeTile(rng, keyRoom)})
+	}
+}
+
+// below reports whether r is reached through the door into top.
+func (d *dungeon) below(r *room, top *room) bool {
+	for {
+		if r == top {
+			return true
+		}
+		if r == d.rooms[0] {
+			return false
+		}
+		r = d.rooms[r.parent]
+	}
+}
+
+// solvable plays the dungeon through, picking up every key that can be
+// reached and spending them on locked doors, to make sure the end can be
+// reached. When locked is set the end must also be out of reach until a
+// door is unlocked, so corridors can't be used to skip the locks.
+func (d *dungeon) solvable(locked bool) bool {
+	keys := map[point]bool{}
+	for _, o := range d.objects {
+		if o.name == "key" {
+			keys[o.pos] = true
+		}
+	}
+	open := map[point]bool{}
+	if locked && d.flood(open)[d.end] {
+		return false
+	}
+	held := 0
+	for {
+		reached := d.flood(open)
+		if reached[d.end] {
+			return true
+		}
+		for _, p := range sortedPoints(keys) {
+			if reached[p] {
+				delete(keys, p)
+				held++
+			}
+		}
+		// which doors the keys go on can decide the outcome, so they're
+		// spent in a fixed order to keep a seed's dungeon the same
+		opened := false
+		for _, p := range sortedPoints(reached) {
+			for _, n := range neighbours(p) {
+				if held > 0 && d.cells[n.x][n.y] == lockedDoorCell && !open[n] {
+					open[n] = true
+					held--
+					opened = true
+				}
+			}
+		}
+		if !opened {
+			return false
+		}
+	}
+}
+
+func (d *dungeon) flood(open map[point]bool) map[point]bool {
+	reached := map[point]bool{}
+	todo := []point{d.start}
+	for len(todo) > 0 {
+		p := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if reached[p] || p.x < 0 || p.y < 0 || p.x >= d.width || p.y >= d.height {
+			continue
+		}
+		c := d.cells[p.x][p.y]
+		if c == wallCell || (c == lockedDoorCell && !open[p]) {
+			continue
+		}
+		reached[p] = true
+		todo = append(todo, neighbours(p)...)
+	}
+	return reached
+}
+
+// sortedPoints returns the points in m ordered by x, then y.
+func sortedPoints(m map[point]bool) []point {
+	points := make([]point, 0, len(m))
+	for p := range m {
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].x != points[j].x {
+			return points[i].x < points[j].x
+		}
+		return points[i].y < points[j].y
+	})
+	return points
+}
+
+func neighbours(p point) []point {
+	return []point{{p.x + 1, p.y}, {p.x - 1, p.y}, {p.x, p.y + 1}, {p.x, p.y - 1}}
+}
+
+// populate fills the rooms with enemies, pickups and scenery. The start
+// room is left empty apart from the odd pickup.
+func (d *dungeon) populate(rng *rand.Rand, opts options) {
+	for i, r := range d.rooms {
+		if i > 0 && len(opts.enemyNames) > 0 {
+			for n := rng.Intn(opts.enemies + 1); n > 0; n-- {
+				name := opts.enemyNames[rng.Intn(len(opts.enemyNames))]
+				d.objects = append(d.objects, object{objectType: "enemy", name: name, pos: d.freeTile(rng, r)})
+			}
+		}
+		if rng.Intn(2) == 0 {
+			name := "ammo"
+			if rng.Intn(3) == 0 {
+				name = "health"
+			}
+			d.objects = append(d.objects, object{objectType: "pickup", name: name, pos: d.freeTile(rng, r)})
+		}
+		if len(opts.sceneryNames) > 0 && r.w > minRoomSize && r.h > minRoomSize {
+			name := opts.sceneryNames[rng.Intn(len(opts.sceneryNames))]
+			for _, corner := range []point{{r.x, r.y}, {r.x + r.w - 1, r.y}, {r.x, r.y + r.h - 1}, {r.x + r.w - 1, r.y + r.h - 1}} {
+				if rng.Intn(2) == 0 && !d.taken(corner) && !d.nextToDoor(corner) {
+					d.objects = append(d.objects, object{objectType: "scenery", name: name, pos: corner})
+				}
+			}
+		}
+	}
+}
+
+// freeTile picks a tile in r that has nothing on it yet. If the room is full
+// its centre is used.
+func (d *dungeon) freeTile(rng *rand.Rand, r *room) point {
+	for tries := 0; tries < 20; tries++ {
+		p := point{x: r.x + rng.Intn(r.w), y: r.y + rng.Intn(r.h)}
+		if !d.taken(p) && !d.nextToDoor(p) {
+			return p
+		}
+	}
+	return r.center()
+}
+
+func (d *dungeon) taken(p point) bool {
+	if p == d.start || p == d.end {
+		return true
+	}
+	for _, o := range d.objects {
+		if o.pos == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dungeon) nextToDoor(p point) bool {
+	for _, n := range neighbours(p) {
+		if c := d.cells[n.x][n.y]; c == doorCell || c == lockedDoorCell {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Command gen writes a randomly generated dungeon as a Tiled map that the
+// game can load. The same seed always gives the same dungeon.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"raycast.com"
+	"raycast.com/tiledgrid"
+)
+
+const tileSize = 16
+
+func main() {
+	seed := flag.Int64("seed", 0, "seed for the layout, 0 to pick one from the clock")
+	out := flag.String("o", "res/maps/gen.json", "map file to write; its tileset is written next to it")
+	res := flag.String("res", "res", "directory holding the level textures and entity definitions")
+	width := flag.Int("width", 48, "map width in tiles")
+	height := flag.Int("height", 48, "map height in tiles")
+	rooms := flag.Int("rooms", 10, "number of rooms to try to fit in")
+	locks := flag.Int("locks", 1, "number of locked doors on the way to the exit")
+	enemies := flag.Int("enemies", 2, "most enemies in a single room")
+	wallTex := flag.String("wallTex", "wall-1,wall-2,wall-3", "comma separated wall textures, the first used most")
+	floorTex := flag.String("floorTex", "floor", "floor texture")
+	ceilingTex := flag.String("ceilingTex", "ceiling", "ceiling texture")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gen [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	if err := raycast.LoadEntityDefinitions(os.DirFS(*res), "entities.json"); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// sorted, so a seed always picks the same ones
+	enemyNames, err := raycast.EntityNames("enemy")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	sceneryNames, err := raycast.EntityNames("scenery")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	d, err := generate(*seed, options{
+		width:        *width,
+		height:       *height,
+		rooms:        *rooms,
+		locks:        *locks,
+		enemies:      *enemies,
+		enemyNames:   enemyNames,
+		sceneryNames: sceneryNames,
+	})
+	if err != nil {
+		fmt.Printf("seed %d: %v\n", *seed, err)
+		os.Exit(1)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(*out), filepath.Ext(*out))
+	tiles := newTileTypes(strings.Split(*wallTex, ","), *floorTex, *ceilingTex)
+	grid, tileSet := d.tiledGrid(tiles, base+"-tiles.json")
+	grid.Properties = tiledgrid.Properties{
+		{Name: "name", Type: "string", Value: fmt.Sprintf("Dungeon %d", *seed)},
+	}
+
+	dir := filepath.Dir(*out)
+	if err := tiledgrid.WriteMap(*out, grid, tileSet); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: seed %d, %d rooms\n", *out, *seed, len(d.rooms))
+
+	problems, err := raycast.CheckLevel(os.DirFS(dir), filepath.Base(*out), os.DirFS(*res))
+	if err != nil {
+		fmt.Printf("%s: %v\n", *out, err)
+		os.Exit(1)
+	}
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", *out, p)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
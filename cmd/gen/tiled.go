@@ -0,0 +1,143 @@
+package main
+
+import (
+	"raycast.com/tiledgrid"
+)
+
+const (
+	doorTex             = "door"
+	doorWallTex         = "door-wall"
+	doorFloorTex        = "door-floor"
+	lockedDoorTex       = "door-locked"
+	lockedDoorWallTex   = "door-wall-locked"
+	tileSetColumns      = 8
+	objectLayerName     = "Object Layer 1"
+	tileLayerName       = "Tile Layer 1"
+	firstGid            = 1
+	secondaryWallChance = 5
+)
+
+// tileTypes are the tileset ids of each kind of tile the generator places.
+type tileTypes struct {
+	tiles      []*tiledgrid.TileConfig
+	floor      int
+	walls      []int
+	door       [2]int
+	lockedDoor [2]int
+}
+
+// newTileTypes builds the tileset. Doors come in two flavours, indexed by
+// whether they run north to south.
+func newTileTypes(wallTex []string, floorTex string, ceilingTex string) *tileTypes {
+	tt := &tileTypes{}
+	tt.floor = tt.add(tiledgrid.TileData{CeilingTex: ceilingTex, FloorTex: floorTex})
+	for _, tex := range wallTex {
+		tt.walls = append(tt.walls, tt.add(tiledgrid.TileData{Block: true, WallTex: tex}))
+	}
+	for i, north := range []bool{false, true} {
+		tt.door[i] = tt.add(doorTile(north, false))
+		tt.lockedDoor[i] = tt.add(doorTile(north, true))
+	}
+	return tt
+}
+
+func doorTile(north bool, locked bool) tiledgrid.TileData {
+	td := tiledgrid.TileData{
+		Block:      true,
+		Door:       true,
+		North:      north,
+		Locked:     locked,
+		CeilingTex: doorFloorTex,
+		FloorTex:   doorFloorTex,
+		DoorTex:    doorTex,
+		WallTex:    doorWallTex,
+	}
+	if locked {
+		td.DoorTex = lockedDoorTex
+		td.WallTex = lockedDoorWallTex
+	}
+	return td
+}
+
+func (tt *tileTypes) add(td tiledgrid.TileData) int {
+	id := len(tt.tiles)
+	tt.tiles = append(tt.tiles, &tiledgrid.TileConfig{Id: id, Properties: td.Properties()})
+	return id
+}
+
+// tiledGrid lays the dungeon out as a map with one tile layer and one object
+// layer, using a tileset saved as tileSetSource.
+func (d *dungeon) tiledGrid(tt *tileTypes, tileSetSource string) (*tiledgrid.TiledGrid, *tiledgrid.TileSet) {
+	data := make([]int, d.width*d.height)
+	for y := 0; y < d.height; y++ {
+		for x := 0; x < d.width; x++ {
+			var id int
+			north := 0
+			if d.north[point{x, y}] {
+				north = 1
+			}
+			switch d.cells[x][y] {
+			case floorCell:
+				id = tt.floor
+			case doorCell:
+				id = tt.door[north]
+			case lockedDoorCell:
+				id = tt.lockedDoor[north]
+			default:
+				id = tt.wall(x, y)
+			}
+			data[y*d.width+x] = firstGid + id
+		}
+	}
+
+	objects := []tiledgrid.TiledObject{
+		newObject(1, "level", "start", d.start),
+		newObject(2, "level", "end", d.end),
+	}
+	for _, o := range d.objects {
+		objects = append(objects, newObject(len(objects)+1, o.objectType, o.name, o.pos))
+	}
+
+	grid := &tiledgrid.TiledGrid{
+		Width:      d.width,
+		Height:     d.height,
+		TileWidth:  tileSize,
+		TileHeight: tileSize,
+		Layers: []*tiledgrid.Layer{
+			{Name: tileLayerName, Type: "tilelayer", Width: d.width, Height: d.height, Data: data},
+			{Name: objectLayerName, Type: "objectgroup", Objects: objects},
+		},
+		TileSetReferences: []*tiledgrid.TileSetReference{
+			{Source: tileSetSource, FirstGid: firstGid},
+		},
+	}
+
+	return grid, tiledgrid.NewTileSet(tileSetSource, tt.tiles, tileSize, tileSetColumns)
+}
+
+// wall picks the wall tile for x, y. The first wall texture is used most,
+// with the others mixed in now and then. The choice is made from the
+// position so it doesn't use up numbers from the layout's random source.
+func (tt *tileTypes) wall(x int, y int) int {
+	if len(tt.walls) == 1 {
+		return tt.walls[0]
+	}
+	h := uint(x*73856093 ^ y*19349663)
+	if h%secondaryWallChance != 0 {
+		return tt.walls[0]
+	}
+	return tt.walls[1+int(h/secondaryWallChance)%(len(tt.walls)-1)]
+}
+
+// newObject places an object on the middle of the tile at p.
+func newObject(id int, objectType string, name string, p point) tiledgrid.TiledObject {
+	return tiledgrid.TiledObject{
+		Id:     id,
+		Name:   name,
+		Type:   objectType,
+		X:      float64(p.x * tileSize),
+		Y:      float64(p.y * tileSize),
+		Width:  tileSize,
+		Height: tileSize,
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"raycast.com"
+)
+
+func main() {
+	res := flag.String("res", "res", "directory holding the level textures and entity definitions")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: levelcheck [-res dir] map.json ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := raycast.LoadEntityDefinitions(os.DirFS(*res), "entities.json"); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		problems, err := raycast.CheckLevel(os.DirFS(filepath.Dir(path)), filepath.Base(path), os.DirFS(*res))
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", path, p)
+		}
+		if len(problems) > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"math/rand"
+	"os"
+	"runtime"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -14,13 +19,39 @@ func init() {
 }
 
 func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines drawing each frame, 1 to draw on one")
+	viewName := flag.String("view", "classic", "view mode: classic, widescreen or retro")
+	fov := flag.Float64("fov", 0, "horizontal field of view in degrees, 0 for the view mode's own")
+	flag.Parse()
+
+	view, ok := raycast.Views[*viewName]
+	if !ok {
+		log.Fatalf("no view mode %q", *viewName)
+	}
+	if *fov != 0 {
+		view.FOV = *fov
+	}
+
+	if err := raycast.LoadEntityDefinitions(os.DirFS("res"), "entities.json"); err != nil {
+		log.Fatal(err)
+	}
 	g := raycast.NewGame()
-	g.LoadLevel("stars-path.json")
+	g.SetRenderWorkers(*workers)
+	if err := g.SetView(view); err != nil {
+		log.Fatal(err)
+	}
+	if err := g.LoadLevel("stars-path.json"); err != nil {
+		log.Fatal(err)
+	}
 
 	ebiten.SetWindowSize(raycast.WindowWidth, raycast.WindowHeight)
 	ebiten.SetWindowTitle("Raycast DEMO")
 	ebiten.SetCursorMode(ebiten.CursorModeCaptured)
 	if err := ebiten.RunGame(g); err != nil {
+		if errors.Is(err, raycast.ErrLevelComplete) {
+			fmt.Println("level complete!")
+			return
+		}
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,88 @@
+// Command wolfimport converts a map from a Wolfenstein 3D style MAPHEAD and
+// GAMEMAPS pair into a Tiled map the game can load.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"raycast.com"
+	"raycast.com/tiledgrid"
+	"raycast.com/wolfmap"
+)
+
+func main() {
+	mapHead := flag.String("maphead", "MAPHEAD.WL6", "map head file listing where each map starts")
+	gameMaps := flag.String("gamemaps", "GAMEMAPS.WL6", "file holding the compressed maps")
+	codes := flag.String("codes", "res/wolf-codes.json", "code table mapping tile and object codes to tiles and objects")
+	carmack := flag.Bool("carmack", true, "maps are Carmack compressed; turn off for MAPTEMP files")
+	index := flag.Int("map", 0, "index of the map to convert, -1 to list the maps")
+	out := flag.String("o", "res/maps/wolf.json", "map file to write; its tileset is written next to it")
+	res := flag.String("res", "res", "directory holding the level textures and entity definitions")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wolfimport [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if filepath.Dir(*mapHead) != filepath.Dir(*gameMaps) {
+		fmt.Println("the map head and game maps files must be in the same directory")
+		os.Exit(2)
+	}
+	maps, err := wolfmap.LoadMaps(os.DirFS(filepath.Dir(*mapHead)), filepath.Base(*mapHead), filepath.Base(*gameMaps), wolfmap.Options{Carmack: *carmack})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *index < 0 {
+		for i, m := range maps {
+			fmt.Printf("%d: %s (%dx%d)\n", i, m.Name, m.Width, m.Height)
+		}
+		return
+	}
+	if *index >= len(maps) {
+		fmt.Printf("there are only %d maps\n", len(maps))
+		os.Exit(1)
+	}
+	table, err := wolfmap.LoadCodeTable(os.DirFS(filepath.Dir(*codes)), filepath.Base(*codes))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(*out), filepath.Ext(*out))
+	grid, tileSet, problems := table.TiledGrid(maps[*index], base+"-tiles.json")
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", *out, p)
+	}
+
+	dir := filepath.Dir(*out)
+	if err := tiledgrid.WriteMap(*out, grid, tileSet); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// make sure the game can load the result
+	if err := raycast.LoadEntityDefinitions(os.DirFS(*res), "entities.json"); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	checks, err := raycast.CheckLevel(os.DirFS(dir), filepath.Base(*out), os.DirFS(*res))
+	if err != nil {
+		fmt.Printf("%s: %v\n", *out, err)
+		os.Exit(1)
+	}
+	for _, p := range checks {
+		fmt.Printf("%s: %s\n", *out, p)
+	}
+	if len(problems) > 0 || len(checks) > 0 {
+		os.Exit(1)
+	}
+}
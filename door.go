@@ -0,0 +1,123 @@
+package raycast
+
+// milliseconds a door takes to slide fully open or shut
+const doorSlideTime = 1000.0
+
+// a door stops anything passing through until it has slid this far open
+const doorPassableOpen = 0.75
+
+// seconds an open door waits before closing by itself
+const defaultDoorCloseDelay = 5.0
+
+type doorState int
+
+const (
+	doorClosed doorState = iota
+	doorOpening
+	doorOpen
+	doorClosing
+)
+
+// findDoors lists the door tiles so they can be slid each update. Doors the
+// map leaves open wait the usual delay before closing.
+func (w *World) findDoors() {
+	w.doors = nil
+	for x, column := range w.tiles {
+		for y, t := range column {
+			if t != nil && t.door {
+				if t.doorState == doorOpen {
+					t.doorTimer = w.settings.doorCloseDelay * 1000
+				}
+				w.doors = append(w.doors, mapPos{x: x, y: y})
+			}
+		}
+	}
+}
+
+// updateDoors slides moving doors along and closes doors that have been open
+// for the level's doorCloseDelay. A door won't close on anything standing in
+// the doorway, and opens again if something steps in while it's closing.
+func (w *World) updateDoors(delta float64) {
+	for _, pos := range w.doors {
+		t := w.tiles[pos.x][pos.y]
+		switch t.doorState {
+		case doorOpening:
+			t.doorOpen += delta / doorSlideTime
+			if t.doorOpen >= 1 {
+				t.doorOpen = 1
+				t.doorState = doorOpen
+				t.doorTimer = w.settings.doorCloseDelay * 1000
+			}
+		case doorOpen:
+			if w.settings.doorCloseDelay <= 0 {
+				break
+			}
+			t.doorTimer -= delta
+			if t.doorTimer <= 0 && !w.closeDoor(pos) {
+				// try again once the doorway has had time to clear
+				t.doorTimer = w.settings.doorCloseDelay * 1000
+			}
+		case doorClosing:
+			if w.doorwayOccupied(pos) {
+				w.openDoor(pos)
+				break
+			}
+			t.doorOpen -= delta / doorSlideTime
+			if t.doorOpen <= 0 {
+				t.doorOpen = 0
+				t.doorState = doorClosed
+			}
+		}
+		t.block = t.doorOpen < doorPassableOpen
+	}
+}
+
+// openDoor starts the door at pos sliding open.
+func (w *World) openDoor(pos mapPos) {
+	t := w.tiles[pos.x][pos.y]
+	if t.doorState == doorOpening || t.doorState == doorOpen {
+		return
+	}
+	t.doorState = doorOpening
+	w.soundPlayer.PlaySound("door")
+}
+
+// closeDoor starts the door at pos sliding shut, unless something is in the
+// way. It reports whether the door is closing.
+func (w *World) closeDoor(pos mapPos) bool {
+	t := w.tiles[pos.x][pos.y]
+	if t.doorState == doorClosed || t.doorState == doorClosing {
+		return true
+	}
+	if w.doorwayOccupied(pos) {
+		return false
+	}
+	t.doorState = doorClosing
+	w.soundPlayer.PlaySound("door")
+	return true
+}
+
+// doorwayOccupied reports whether the player or anything solid overlaps the
+// door tile at pos.
+func (w *World) doorwayOccupied(pos mapPos) bool {
+	if inDoorway(pos, w.player.pos, w.player.width) {
+		return true
+	}
+	for _, e := range w.enemies {
+		if e.entity.state != DeadEntityState && inDoorway(pos, e.entity.pos, e.entity.width) {
+			return true
+		}
+	}
+	for _, s := range w.scenery {
+		if s.entity.state != DeadEntityState && inDoorway(pos, s.entity.pos, s.entity.width) {
+			return true
+		}
+	}
+	return false
+}
+
+func inDoorway(pos mapPos, p vector, width float64) bool {
+	r := width / 2
+	return p.x+r > float64(pos.x) && p.x-r < float64(pos.x+1) &&
+		p.y+r > float64(pos.y) && p.y-r < float64(pos.y+1)
+}
@@ -1,46 +1,28 @@
 package raycast
 
 type effect struct {
-	entity *entity
-	timer  float64
+	entity    *entity
+	timer     float64
+	explosion bool
 }
 
 type effectType string
 
 const (
-	sceneryDestroyedEffectType = "scenery-destroyed"
-	bulletHitEffectType        = "bullet-hit"
-	explosionEffectType        = "explosion"
+	bulletHitEffectType = "bullet-hit"
 )
 
+// effects the game spawns by name rather than through a definition, which
+// the entity definitions have to provide
+var namedEffectTypes = []effectType{bulletHitEffectType}
+
 func NewEffect(effectType effectType, pos vector) *effect {
-	var timing float64
-	var numFrames int
-	var img string
-	switch effectType {
-	case bulletHitEffectType:
-		timing = 0.08 * 1000
-		numFrames = 4
-		img = "bullet-hit"
-		break
-	case sceneryDestroyedEffectType:
-		timing = 0.16 * 1000
-		numFrames = 4
-		img = "grey-hit-effect"
-		break
-	case explosionEffectType:
-		timing = 0.08 * 1000
-		numFrames = 8
-		img = "explosion"
-		break
-	}
+	def := entityDefs.Effects[string(effectType)]
+	s := def.Sprites[0]
 	e := &effect{
-		entity: NewEntity(pos, NewAnimatedSprite(img, &animation{
-			numFrames: numFrames,
-			numTime:   timing,
-			isLoop:    true,
-		})),
-		timer: float64(numFrames) * timing,
+		entity:    NewEntity(pos, def.newSprites()...),
+		timer:     float64(s.Frames) * s.FrameTime * 1000,
+		explosion: def.Explosion,
 	}
 	return e
 }
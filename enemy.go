@@ -1,6 +1,7 @@
 package raycast
 
 type enemy struct {
+	def               *entityDef
 	entity            *entity
 	currentHurtTime   float64
 	currentAttackTime float64
@@ -8,142 +9,43 @@ type enemy struct {
 	canSeePlayer      bool
 	lastKnowPlayerPos vector
 	enemyType         EnemyType
+	attack            attackType
 	attackRange       float64
+	// asleep enemies ignore the player until they are hurt
+	asleep bool
+	// ambush enemies only notice the player in front of them
+	ambush bool
 }
 
 type EnemyType string
 
+type attackType string
+
 const (
-	blueEnemyType  = "blue-enemy"
-	ballEnemyType  = "ball-enemy"
-	alienEnemyType = "alien-enemy"
-	blobEnemyType  = "blob-enemy"
+	noAttackType     attackType = ""
+	meleeAttackType  attackType = "melee"
+	rangedAttackType attackType = "ranged"
 )
 
-func NewEnemy(enemyType EnemyType, pos vector) *enemy {
+// NewEnemy builds an enemy from its definition. The four sprites are used
+// for moving, getting hurt, attacking and dying, in that order.
+func NewEnemy(enemyType EnemyType, def *entityDef, pos vector) *enemy {
 	e := &enemy{
+		def:         def,
 		enemyType:   enemyType,
-		attackRange: 1.0,
+		attack:      def.Attack,
+		attackRange: def.AttackRange * def.AttackRange, // distance needs to be squared
 		state:       "move",
 	}
-	var ent *entity
-	switch enemyType {
-	case ballEnemyType:
-		ent = NewEntity(
-			pos,
-			NewAnimatedSprite("enemy-ball-move", &animation{
-				numFrames: 4,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("enemy-ball-hurt", &animation{
-				numFrames: 4,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("enemy-ball-attack", &animation{
-				numFrames: 4,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("enemy-ball-die", &animation{
-				numFrames: 4,
-				numTime:   0.1 * 1000,
-				isLoop:    true,
-			}),
-		)
-		ent.speed = 0.003
-		ent.dropItem = ""
-		break
-	case blobEnemyType:
-		ent = NewEntity(
-			pos,
-			NewAnimatedSprite("blob-walk", &animation{
-				numFrames: 2,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("blob-hurt", &animation{
-				numFrames: 2,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("blob-attack", &animation{
-				numFrames: 2,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("blob-die", &animation{
-				numFrames: 4,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-		)
-		ent.speed = 0.0015
-		ent.health = 2
-		ent.dropItem = ""
-		break
-	case blueEnemyType:
-		e.attackRange = 8 * 8 // distance needs to be squared
-
-		ent = NewEntity(
-			pos,
-			NewAnimatedSprite("enemy-blue-move", &animation{
-				numFrames: 4,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("enemy-blue-hurt", &animation{
-				numFrames: 4,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("enemy-blue-attack", &animation{
-				numFrames: 4,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("enemy-blue-die", &animation{
-				numFrames: 4,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-		)
-		ent.speed = 0.001
-		ent.dropItem = ""
-	case alienEnemyType:
-		e.attackRange = 8 * 8 // distance needs to be squared
-
-		ent = NewEntity(
-			pos,
-			NewAnimatedSprite("alien-walk", &animation{
-				numFrames: 2,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("alien-hurt", &animation{
-				numFrames: 4,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("alien-attack", &animation{
-				numFrames: 4,
-				numTime:   0.15 * 1000,
-				isLoop:    true,
-			}),
-			NewAnimatedSprite("alien-die", &animation{
-				numFrames: 4,
-				numTime:   0.2 * 1000,
-				isLoop:    true,
-			}),
-		)
-		ent.speed = 0.001
-		ent.health = 2
-		ent.dropItem = ""
+	ent := NewEntity(pos, def.newSprites()...)
+	if def.Speed != 0 {
+		ent.speed = def.Speed
 	}
+	ent.health = def.Health
+	ent.dropItem = def.Drop
 
 	e.entity = ent
-	e.entity.isPhysicsEntity = true
+	e.entity.isPhysicsEntity = def.Physics
 
 	return e
 }
@@ -173,7 +75,7 @@ func (r *enemy) Update(w *World, delta float64) {
 		}
 		break
 	case "move":
-		if r.entity.CurrentSprite().distance != -1 && r.entity.CurrentSprite().distance < r.attackRange {
+		if !r.asleep && r.entity.CurrentSprite().distance != -1 && r.entity.CurrentSprite().distance < r.attackRange {
 			r.entity.SetCurrentSprite(2)
 			r.state = "attack"
 			anim := r.entity.CurrentSprite().animation
@@ -181,9 +83,8 @@ func (r *enemy) Update(w *World, delta float64) {
 		}
 		break
 	case "attack":
-		switch r.enemyType {
-		case ballEnemyType:
-		case blobEnemyType:
+		switch r.attack {
+		case meleeAttackType:
 			if r.currentAttackTime > 0 {
 				r.currentAttackTime -= delta
 			} else {
@@ -195,8 +96,7 @@ func (r *enemy) Update(w *World, delta float64) {
 				r.state = "move"
 			}
 			break
-		case blueEnemyType:
-		case alienEnemyType:
+		case rangedAttackType:
 			r.entity.state = StoppedEntityState
 			if r.currentAttackTime > 0 {
 				r.currentAttackTime -= delta
@@ -247,22 +147,38 @@ func (r *enemy) Update(w *World, delta float64) {
 			x: w.player.pos.x - r.entity.pos.x,
 			y: w.player.pos.y - r.entity.pos.y,
 		})
+		r.entity.facing = r.entity.dir
 	} else {
 		if within(r.entity.pos, r.lastKnowPlayerPos, 0.25) {
 			r.entity.dir.x = 0
 			r.entity.dir.y = 0
 		}
 		canSee, _ := canSeePos(w, r.entity.pos, w.player.pos)
-		if canSee {
+		if canSee && r.noticesPlayer(w) {
 			r.canSeePlayer = true
 		}
 	}
 }
 
+func (r *enemy) noticesPlayer(w *World) bool {
+	if r.asleep {
+		return false
+	}
+	if r.ambush {
+		toPlayer := vector{
+			x: w.player.pos.x - r.entity.pos.x,
+			y: w.player.pos.y - r.entity.pos.y,
+		}
+		return toPlayer.x*r.entity.facing.x+toPlayer.y*r.entity.facing.y > 0
+	}
+	return true
+}
+
 func (r *enemy) TakeDamage(w *World, amount int) {
 	if r.state == "dying" {
 		return
 	}
+	r.asleep = false
 	r.entity.health -= amount
 	r.entity.SetCurrentSprite(1)
 	anim := r.entity.CurrentSprite().animation
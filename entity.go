@@ -9,6 +9,7 @@ type entity struct {
 	pos             vector
 	width           float64
 	dir             vector
+	facing          vector
 	isPhysicsEntity bool
 	physics         []*vector
 	speed           float64
@@ -28,10 +29,15 @@ const (
 )
 
 const entitySpeed = 0.002
+const entityWidth = (1.0 / TextureWidth) * 20.0
 const physicsDampening = 0.9
 const physicsZeroThreshold = 0.01
 
 func NewEntity(pos vector, sprites ...*sprite) *entity {
+	// draw the sprites in place before the entity's first update
+	for _, s := range sprites {
+		s.pos = pos
+	}
 	return &entity{
 		sprites:         sprites,
 		pos:             pos,
@@ -39,7 +45,7 @@ func NewEntity(pos vector, sprites ...*sprite) *entity {
 		speed:           entitySpeed,
 		health:          1,
 		state:           NothingEntityState,
-		width:           (1.0 / TextureWidth) * 20.0,
+		width:           entityWidth,
 		physics:         []*vector{},
 		isPhysicsEntity: false,
 	}
@@ -0,0 +1,223 @@
+package raycast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// entityDefs holds the definitions used to build enemies, scenery, pickups
+// and effects. It is loaded by LoadEntityDefinitions, which has to be called
+// before any level is loaded.
+var entityDefs *entityDefinitions
+
+type entityDefinitions struct {
+	Enemies map[string]*entityDef `json:"enemy"`
+	Scenery map[string]*entityDef `json:"scenery"`
+	Pickups map[string]*entityDef `json:"pickup"`
+	Effects map[string]*entityDef `json:"effect"`
+}
+
+// entityDef describes one kind of entity. Fields that do not apply to a kind
+// are ignored.
+type entityDef struct {
+	// key of the definition in its section of the file
+	name string
+	// enemies need four sprites: move, hurt, attack and die
+	Sprites []*spriteDef `json:"sprites"`
+	Health  int          `json:"health"`
+	Speed   float64      `json:"speed"`
+	Physics bool         `json:"physics"`
+	Collide bool         `json:"collide"`
+	// effect played and sound made when scenery is destroyed
+	Effect effectType `json:"effect"`
+	Sound  string     `json:"sound"`
+	// pickup created when the entity dies, or "end" for a portal
+	Drop        string     `json:"drop"`
+	Attack      attackType `json:"attack"`
+	AttackRange float64    `json:"attackRange"`
+	Pickup      pickupType `json:"pickup"`
+	Amount      int        `json:"amount"`
+	// amount given when dropped by another entity, if different
+	DropAmount int `json:"dropAmount"`
+	// effects that push physics entities away
+	Explosion bool `json:"explosion"`
+}
+
+type spriteDef struct {
+	Image string `json:"image"`
+	// frames laid out horizontally, 0 for a still image
+	Frames int `json:"frames"`
+	// seconds per frame
+	FrameTime float64 `json:"frameTime"`
+	Height    float64 `json:"height"`
+}
+
+func (r *spriteDef) newSprite() *sprite {
+	var s *sprite
+	if r.Frames > 0 {
+		s = NewAnimatedSprite(r.Image, &animation{
+			numFrames: r.Frames,
+			numTime:   r.FrameTime * 1000,
+			isLoop:    true,
+		})
+	} else {
+		s = NewSprite(r.Image)
+	}
+	s.height = r.Height
+	return s
+}
+
+func (r *entityDef) newSprites() []*sprite {
+	sprites := make([]*sprite, len(r.Sprites))
+	for i, sd := range r.Sprites {
+		sprites[i] = sd.newSprite()
+	}
+	return sprites
+}
+
+// LoadEntityDefinitions reads and validates the entity definitions file.
+func LoadEntityDefinitions(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("opening entity definitions: %w", err)
+	}
+	var defs entityDefinitions
+	if err = json.Unmarshal(data, &defs); err != nil {
+		return fmt.Errorf("parsing entity definitions %s: %w", name, err)
+	}
+	for _, section := range []map[string]*entityDef{defs.Enemies, defs.Scenery, defs.Pickups, defs.Effects} {
+		for name, def := range section {
+			def.name = name
+		}
+	}
+	if err = defs.validate(); err != nil {
+		return fmt.Errorf("entity definitions %s: %w", name, err)
+	}
+	entityDefs = &defs
+	return nil
+}
+
+func loadedEntityDefinitions() (*entityDefinitions, error) {
+	if entityDefs == nil {
+		return nil, errors.New("entity definitions not loaded: call LoadEntityDefinitions first")
+	}
+	return entityDefs, nil
+}
+
+// EntityNames returns the names of the entities of a Tiled object type,
+// enemy, scenery or pickup, that maps can place, sorted.
+func EntityNames(objectType string) ([]string, error) {
+	defs, err := loadedEntityDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	var section map[string]*entityDef
+	switch objectType {
+	case "enemy":
+		section = defs.Enemies
+	case "scenery":
+		section = defs.Scenery
+	case "pickup":
+		section = defs.Pickups
+	default:
+		return nil, fmt.Errorf("no entities of type %q", objectType)
+	}
+	names := make([]string, 0, len(section))
+	for name := range section {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// lookup finds the definition for a Tiled object type and name, or nil.
+func (r *entityDefinitions) lookup(objectType string, name string) *entityDef {
+	switch objectType {
+	case "enemy":
+		return r.Enemies[name]
+	case "scenery":
+		return r.Scenery[name]
+	case "pickup":
+		return r.Pickups[name]
+	}
+	return nil
+}
+
+// sounds returns the sounds any definition can play.
+func (r *entityDefinitions) sounds() []string {
+	var sounds []string
+	for _, def := range r.Scenery {
+		if def.Sound != "" {
+			sounds = append(sounds, def.Sound)
+		}
+	}
+	return sounds
+}
+
+func (r *entityDefinitions) validate() error {
+	for _, name := range namedEffectTypes {
+		if _, ok := r.Effects[string(name)]; !ok {
+			return fmt.Errorf("missing effect %q", name)
+		}
+	}
+	for name, def := range r.Enemies {
+		if len(def.Sprites) != 4 {
+			return fmt.Errorf("enemy %q: needs 4 sprites (move, hurt, attack, die), has %d", name, len(def.Sprites))
+		}
+		for _, sd := range def.Sprites {
+			if sd.Frames < 1 {
+				return fmt.Errorf("enemy %q: sprite %q needs at least 1 frame", name, sd.Image)
+			}
+		}
+		switch def.Attack {
+		case noAttackType, meleeAttackType, rangedAttackType:
+		default:
+			return fmt.Errorf("enemy %q: unknown attack %q", name, def.Attack)
+		}
+		if err := r.validateCommon(def); err != nil {
+			return fmt.Errorf("enemy %q: %w", name, err)
+		}
+	}
+	for name, def := range r.Scenery {
+		if _, ok := r.Effects[string(def.Effect)]; !ok {
+			return fmt.Errorf("scenery %q: unknown effect %q", name, def.Effect)
+		}
+		if err := r.validateCommon(def); err != nil {
+			return fmt.Errorf("scenery %q: %w", name, err)
+		}
+	}
+	for name, def := range r.Pickups {
+		switch def.Pickup {
+		case ammoPickupType, healthPickupType, soulPickupType, bookPickupType, keyPickupType:
+		default:
+			return fmt.Errorf("pickup %q: unknown pickup %q", name, def.Pickup)
+		}
+		if err := r.validateCommon(def); err != nil {
+			return fmt.Errorf("pickup %q: %w", name, err)
+		}
+	}
+	for name, def := range r.Effects {
+		if err := r.validateCommon(def); err != nil {
+			return fmt.Errorf("effect %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *entityDefinitions) validateCommon(def *entityDef) error {
+	if len(def.Sprites) == 0 {
+		return fmt.Errorf("no sprites")
+	}
+	for _, sd := range def.Sprites {
+		if sd.Image == "" {
+			return fmt.Errorf("sprite with no image")
+		}
+	}
+	if _, ok := r.Pickups[def.Drop]; def.Drop != "" && def.Drop != "end" && !ok {
+		return fmt.Errorf("unknown drop %q", def.Drop)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package raycast
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEntityDefinitionsEffects(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(defs map[string]map[string]map[string]interface{})
+		want string
+	}{
+		{
+			name: "as shipped",
+			edit: func(defs map[string]map[string]map[string]interface{}) {},
+		},
+		{
+			name: "no bullet hit",
+			edit: func(defs map[string]map[string]map[string]interface{}) {
+				delete(defs["effect"], string(bulletHitEffectType))
+			},
+			want: `missing effect "bullet-hit"`,
+		},
+		{
+			name: "scenery with an unknown effect",
+			edit: func(defs map[string]map[string]map[string]interface{}) {
+				defs["scenery"]["barrel"]["effect"] = "fizzle"
+			},
+			want: `scenery "barrel": unknown effect "fizzle"`,
+		},
+	}
+	data, err := os.ReadFile("res/entities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var defs map[string]map[string]map[string]interface{}
+			if err := json.Unmarshal(data, &defs); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(defs)
+			edited, err := json.Marshal(defs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fsys := fstest.MapFS{"entities.json": {Data: edited}}
+			err = LoadEntityDefinitions(fsys, "entities.json")
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityNames(t *testing.T) {
+	names, err := EntityNames("scenery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("got unsorted names %q", names)
+	}
+	if len(names) != len(entityDefs.Scenery) {
+		t.Errorf("got %d scenery names, want %d", len(names), len(entityDefs.Scenery))
+	}
+	for _, name := range names {
+		if entityDefs.lookup("scenery", name) == nil {
+			t.Errorf("scenery %q has no definition", name)
+		}
+	}
+	if _, err := EntityNames("level"); err == nil {
+		t.Error("no error listing level objects")
+	}
+}
@@ -0,0 +1,299 @@
+package raycast
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"raycast.com/tiledgrid"
+)
+
+// tiles per row in an exported tileset image
+const exportTileSetColumns = 8
+
+// ExportTiled converts the world back into a Tiled map. Floors, walls and
+// ceilings go on their own layers so every tile keeps all its textures, and
+// the player start, portals, exits, spawns, enemies, pickups and scenery go
+// on an object layer. The tileset holding the tile types is returned separately and is
+// referenced from the map as tileSetSource.
+func (w *World) ExportTiled(tileSetSource string) (*tiledgrid.TiledGrid, *tiledgrid.TileSet) {
+	ex := &tiledExport{
+		ids: map[exportTileType]int{},
+	}
+	grid := &tiledgrid.TiledGrid{
+		Width:      w.width,
+		Height:     w.height,
+		TileWidth:  GridTileSize,
+		TileHeight: GridTileSize,
+		TileSetReferences: []*tiledgrid.TileSetReference{
+			{Source: tileSetSource, FirstGid: 1},
+		},
+		Properties: w.settings.export(),
+	}
+	grid.Layers = []*tiledgrid.Layer{
+		ex.tileLayer(w, floorLayerName, func(t *tile) (tiledgrid.TileData, textureFlip) {
+			return tiledgrid.TileData{FloorTex: t.floorTex}, t.floorFlip
+		}),
+		ex.tileLayer(w, wallLayerName, func(t *tile) (tiledgrid.TileData, textureFlip) {
+			return tiledgrid.TileData{
+				Block:    t.block,
+				Door:     t.door,
+				North:    t.north,
+				Locked:   t.locked,
+				WallTex:  t.wallTex,
+				WallTexN: t.wallTexN,
+				WallTexS: t.wallTexS,
+				WallTexE: t.wallTexE,
+				WallTexW: t.wallTexW,
+				DoorTex:  t.doorTex,
+			}, t.wallFlip
+		}),
+		ex.tileLayer(w, ceilingLayerName, func(t *tile) (tiledgrid.TileData, textureFlip) {
+			return tiledgrid.TileData{CeilingTex: t.ceilingTex}, t.ceilingFlip
+		}),
+		{
+			Name:    "objects",
+			Type:    "objectgroup",
+			Objects: w.exportObjects(),
+		},
+	}
+
+	tileSet := tiledgrid.NewTileSet(tileSetSource, ex.tiles, GridTileSize, exportTileSetColumns)
+	return grid, tileSet
+}
+
+// WriteTiled writes the world to dir as mapFile, along with the tileset it
+// uses and a placeholder tileset image with one coloured square per tile
+// type.
+func (w *World) WriteTiled(dir string, mapFile string) error {
+	base := strings.TrimSuffix(mapFile, filepath.Ext(mapFile))
+	grid, tileSet := w.ExportTiled(base + "-tiles.json")
+	return tiledgrid.WriteMap(filepath.Join(dir, mapFile), grid, tileSet)
+}
+
+type tiledExport struct {
+	ids   map[exportTileType]int
+	tiles []*tiledgrid.TileConfig
+}
+
+// exportTileType is a tile in the exported tileset. Tiles with the same
+// textures are only the same tile if they animate the same way.
+type exportTileType struct {
+	td tiledgrid.TileData
+	// texture that animates, if any, and its animation
+	animated  string
+	animation *textureAnimation
+}
+
+// tileLayer builds a tile layer from the part of each tile that split picks
+// out. Empty tiles are left as gid 0.
+func (ex *tiledExport) tileLayer(w *World, name string, split func(t *tile) (tiledgrid.TileData, textureFlip)) *tiledgrid.Layer {
+	l := &tiledgrid.Layer{
+		Name:   name,
+		Type:   "tilelayer",
+		Width:  w.width,
+		Height: w.height,
+		Data:   make([]int, w.width*w.height),
+	}
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			t := w.tiles[x][y]
+			if t == nil {
+				continue
+			}
+			td, flip := split(t)
+			if td == (tiledgrid.TileData{}) {
+				continue
+			}
+			l.Data[y*w.width+x] = tiledgrid.JoinGid(ex.id(td, t.animations)+1, tiledgrid.Flip{
+				Horizontal: flip.horizontal,
+				Vertical:   flip.vertical,
+				Diagonal:   flip.diagonal,
+			})
+		}
+	}
+	return l
+}
+
+// id returns the tileset id for a tile type, adding it if it's new. Only
+// the first of its textures that animations animates is exported animated.
+func (ex *tiledExport) id(td tiledgrid.TileData, animations map[string]*textureAnimation) int {
+	key := exportTileType{td: td}
+	for _, texture := range []string{td.WallTex, td.WallTexN, td.WallTexS, td.WallTexE, td.WallTexW, td.FloorTex, td.CeilingTex, td.DoorTex} {
+		if a, ok := animations[texture]; ok && texture != "" {
+			key.animated, key.animation = texture, a
+			break
+		}
+	}
+	if id, ok := ex.ids[key]; ok {
+		return id
+	}
+	id := len(ex.tiles)
+	ex.ids[key] = id
+
+	tc := &tiledgrid.TileConfig{Id: id, Properties: td.Properties()}
+	ex.tiles = append(ex.tiles, tc)
+	if key.animation == nil {
+		return id
+	}
+
+	// an animated tile becomes a tile per frame with the texture swapped
+	for i, frame := range key.animation.frames {
+		frameTd := td
+		for _, texture := range []*string{&frameTd.WallTex, &frameTd.WallTexN, &frameTd.WallTexS, &frameTd.WallTexE, &frameTd.WallTexW, &frameTd.FloorTex, &frameTd.CeilingTex, &frameTd.DoorTex} {
+			if *texture == key.animated {
+				*texture = frame
+			}
+		}
+		tc.Animation = append(tc.Animation, tiledgrid.TileFrame{
+			TileId:   ex.id(frameTd, nil),
+			Duration: int(key.animation.durations[i]),
+		})
+	}
+	return id
+}
+
+func (w *World) exportObjects() []tiledgrid.TiledObject {
+	objects := []tiledgrid.TiledObject{}
+	add := func(objectType string, name string, e *entity, facing vector, props tiledgrid.Properties) {
+		size := GridTileSize * e.width / entityWidth
+		if e.width == 0 {
+			size = GridTileSize
+		}
+		objects = append(objects, newTiledObject(len(objects)+1, objectType, name, e.pos, facing, size, props))
+	}
+
+	if w.player != nil {
+		objects = append(objects, newTiledObject(len(objects)+1, "level", "start", w.player.pos, w.player.dir, GridTileSize, nil))
+	}
+	for _, p := range w.portals {
+		if p.exit == nil {
+			add("level", "end", p.entity, p.entity.facing, nil)
+			continue
+		}
+		props := tiledgrid.Properties{{Name: "map", Type: "file", Value: p.exit.mapFile}}
+		if p.exit.spawn != "" {
+			props = append(props, &tiledgrid.TileConfigProp{Name: "spawn", Type: "string", Value: p.exit.spawn})
+		}
+		add("exit", "", p.entity, p.entity.facing, props)
+	}
+	names := make([]string, 0, len(w.spawns))
+	for name := range w.spawns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spawn := w.spawns[name]
+		objects = append(objects, newTiledObject(len(objects)+1, "spawn", name, spawn.pos, spawn.facing, GridTileSize, nil))
+	}
+	for _, e := range w.enemies {
+		props := entityProperties(e.entity, e.def)
+		if e.asleep {
+			props = append(props, &tiledgrid.TileConfigProp{Name: "asleep", Type: "bool", Value: true})
+		}
+		if e.ambush {
+			props = append(props, &tiledgrid.TileConfigProp{Name: "ambush", Type: "bool", Value: true})
+		}
+		add("enemy", string(e.enemyType), e.entity, e.entity.facing, props)
+	}
+	for _, p := range w.pickups {
+		if p.def == nil {
+			continue
+		}
+		var props tiledgrid.Properties
+		if p.amount != p.def.Amount {
+			props = append(props, &tiledgrid.TileConfigProp{Name: "amount", Type: "int", Value: float64(p.amount)})
+		}
+		add("pickup", p.def.name, p.entity, p.entity.facing, props)
+	}
+	for _, s := range w.scenery {
+		if s.def == nil {
+			continue
+		}
+		add("scenery", s.def.name, s.entity, s.entity.facing, entityProperties(s.entity, s.def))
+	}
+	return objects
+}
+
+// entityProperties returns the properties needed to give e the health,
+// speed and drop it has now when it's loaded again from def.
+func entityProperties(e *entity, def *entityDef) tiledgrid.Properties {
+	var props tiledgrid.Properties
+	if def == nil {
+		return props
+	}
+	if e.health != def.Health {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "health", Type: "int", Value: float64(e.health)})
+	}
+	speed := def.Speed
+	if speed == 0 {
+		speed = entitySpeed
+	}
+	if e.speed != speed {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "speed", Type: "float", Value: e.speed})
+	}
+	if e.dropItem != def.Drop {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "drop", Type: "string", Value: e.dropItem})
+	}
+	return props
+}
+
+// newTiledObject places a square object so that its centre, once rotated
+// around the top left corner as Tiled does, lands on pos.
+func newTiledObject(id int, objectType string, name string, pos vector, facing vector, size float64, props tiledgrid.Properties) tiledgrid.TiledObject {
+	angle := 0.0
+	if facing.x != 0 || facing.y != 0 {
+		angle = math.Atan2(facing.y, facing.x)
+	}
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	half := size / 2
+	return tiledgrid.TiledObject{
+		Id:         id,
+		Name:       name,
+		Type:       objectType,
+		X:          roundExport(pos.x*GridTileSize - (half*cos - half*sin)),
+		Y:          roundExport(pos.y*GridTileSize - (half*sin + half*cos)),
+		Width:      roundExport(size),
+		Height:     roundExport(size),
+		Rotation:   roundExport(angle * 180 / math.Pi),
+		Properties: props,
+	}
+}
+
+func roundExport(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// export returns the map properties needed to load the same settings again.
+func (s *levelSettings) export() tiledgrid.Properties {
+	props := tiledgrid.Properties{}
+	def := defaultLevelSettings()
+	if s.name != def.name {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "name", Type: "string", Value: s.name})
+	}
+	if s.sky != def.sky {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "sky", Type: "string", Value: s.sky})
+	}
+	if s.fogColor != def.fogColor {
+		c := s.fogColor
+		props = append(props, &tiledgrid.TileConfigProp{Name: "fogColor", Type: "color", Value: fmt.Sprintf("#%02x%02x%02x%02x", c.A, c.R, c.G, c.B)})
+	}
+	if s.fogDistance != def.fogDistance {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "fogDistance", Type: "float", Value: s.fogDistance})
+	}
+	if s.ambientLight != def.ambientLight {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "ambientLight", Type: "float", Value: s.ambientLight})
+	}
+	if s.music != def.music {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "music", Type: "string", Value: s.music})
+	}
+	if s.parTime != def.parTime {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "parTime", Type: "float", Value: s.parTime})
+	}
+	if s.doorCloseDelay != def.doorCloseDelay {
+		props = append(props, &tiledgrid.TileConfigProp{Name: "doorCloseDelay", Type: "float", Value: s.doorCloseDelay})
+	}
+	return props
+}
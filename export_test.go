@@ -0,0 +1,121 @@
+package raycast
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestWriteTiled exports levels as Tiled maps and checks they load back as
+// the same level.
+func TestWriteTiled(t *testing.T) {
+	for _, mapFile := range []string{"library.json", "cellar.txt"} {
+		mapFile := mapFile
+		t.Run(mapFile, func(t *testing.T) {
+			want, err := NewWorldFS(os.DirFS("res/maps"), mapFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if err := want.WriteTiled(dir, "exported.json"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewWorldFS(os.DirFS(dir), "exported.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.width != want.width || got.height != want.height {
+				t.Fatalf("got %dx%d map, want %dx%d", got.width, got.height, want.width, want.height)
+			}
+			for x := 0; x < want.width; x++ {
+				for y := 0; y < want.height; y++ {
+					if g, w := exportedTile(got.tiles[x][y]), exportedTile(want.tiles[x][y]); g != w {
+						t.Errorf("tile %d,%d: got %+v, want %+v", x, y, g, w)
+					}
+				}
+			}
+			if *got.settings != *want.settings {
+				t.Errorf("got settings %+v, want %+v", *got.settings, *want.settings)
+			}
+			if g, w := roundedVector(got.player.pos)+roundedVector(got.player.dir), roundedVector(want.player.pos)+roundedVector(want.player.dir); g != w {
+				t.Errorf("got start at %s, want %s", g, w)
+			}
+			if g, w := worldObjects(got), worldObjects(want); !reflect.DeepEqual(g, w) {
+				t.Errorf("got objects\n%q\nwant\n%q", g, w)
+			}
+		})
+	}
+}
+
+// tileTextures is the part of a tile a level sets, without its play state.
+type tileTextures struct {
+	block, door, north, locked              bool
+	wallTex, wallTexN, wallTexS, wallTexE   string
+	wallTexW, floorTex, ceilingTex, doorTex string
+	wallFlip, floorFlip, ceilingFlip        textureFlip
+	animated                                string
+}
+
+func exportedTile(t *tile) tileTextures {
+	if t == nil {
+		return tileTextures{}
+	}
+	tt := tileTextures{
+		block: t.block, door: t.door, north: t.north, locked: t.locked,
+		wallTex: t.wallTex, wallTexN: t.wallTexN, wallTexS: t.wallTexS, wallTexE: t.wallTexE,
+		wallTexW: t.wallTexW, floorTex: t.floorTex, ceilingTex: t.ceilingTex, doorTex: t.doorTex,
+		wallFlip: t.wallFlip, floorFlip: t.floorFlip, ceilingFlip: t.ceilingFlip,
+	}
+	for name, a := range t.animations {
+		tt.animated += fmt.Sprintf("%s%v ", name, a.frames)
+	}
+	return tt
+}
+
+// worldObjects describes every object placed in the world, sorted.
+func worldObjects(w *World) []string {
+	var objects []string
+	for _, e := range w.enemies {
+		objects = append(objects, fmt.Sprintf("enemy %s at %s facing %s asleep %v ambush %v", e.enemyType, roundedVector(e.entity.pos), roundedVector(e.entity.facing), e.asleep, e.ambush))
+	}
+	for _, p := range w.pickups {
+		name := "?"
+		if p.def != nil {
+			name = p.def.name
+		}
+		objects = append(objects, fmt.Sprintf("pickup %s at %s amount %d", name, roundedVector(p.entity.pos), p.amount))
+	}
+	for _, s := range w.scenery {
+		name := "?"
+		if s.def != nil {
+			name = s.def.name
+		}
+		objects = append(objects, fmt.Sprintf("scenery %s at %s facing %s", name, roundedVector(s.entity.pos), roundedVector(s.entity.facing)))
+	}
+	for _, p := range w.portals {
+		if p.exit == nil {
+			objects = append(objects, fmt.Sprintf("end at %s", roundedVector(p.entity.pos)))
+			continue
+		}
+		objects = append(objects, fmt.Sprintf("exit to %s %q at %s", p.exit.mapFile, p.exit.spawn, roundedVector(p.entity.pos)))
+	}
+	for name, s := range w.spawns {
+		objects = append(objects, fmt.Sprintf("spawn %s at %s facing %s", name, roundedVector(s.pos), roundedVector(s.facing)))
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+// roundedVector formats v to a precision that survives exporting, as
+// positions are saved in whole thousandths of a pixel and facings as angles.
+func roundedVector(v vector) string {
+	round := func(f float64) float64 {
+		// adding 0 turns -0 into 0
+		return math.Round(f*1e4)/1e4 + 0
+	}
+	return fmt.Sprintf("(%g, %g)", round(v.x), round(v.y))
+}
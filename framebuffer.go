@@ -0,0 +1,635 @@
+package raycast
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+)
+
+var fakeLightEnabled = false
+
+// drawn on walls that have no texture of their own
+const missingWallTexture = "wall-3"
+
+// Framebuffer draws frames of a World into a plain image, so a frame can be
+// rendered without a window. Renderer uploads it to the screen. Pixels are
+// written straight into the image's RGBA bytes.
+type Framebuffer struct {
+	view     View
+	image    *image.RGBA
+	textures map[string]*texture
+	// world whose textures have all been loaded
+	loaded  *World
+	zbuffer []float64
+	// goroutines drawing bands of columns at the same time, 1 to draw on
+	// the calling goroutine only
+	workers int
+}
+
+func NewFramebuffer() *Framebuffer {
+	r := &Framebuffer{
+		textures: map[string]*texture{},
+		workers:  1,
+	}
+	r.SetView(DefaultView())
+	return r
+}
+
+// SetView changes the resolution, shape and field of view of the frames
+// drawn.
+func (r *Framebuffer) SetView(v View) error {
+	if err := v.validate(); err != nil {
+		return err
+	}
+	r.view = v
+	r.image = image.NewRGBA(image.Rect(0, 0, v.Width, v.Height))
+	r.zbuffer = make([]float64, v.Width)
+	if r.loaded != nil {
+		r.loaded.player.setFOV(v.FOV)
+	}
+	return nil
+}
+
+// SetWorld readies the framebuffer to draw w, loading the level's textures
+// and fitting the player's camera plane to the view so what's drawn fits
+// the frame.
+func (r *Framebuffer) SetWorld(w *World) {
+	r.LoadAllLevelTextures(w)
+	w.player.setFOV(r.view.FOV)
+}
+
+// SetWorkers sets how many goroutines draw the view, each taking a band of
+// screen columns. The frame comes out the same however many there are.
+func (r *Framebuffer) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	r.workers = n
+}
+
+// Render draws what the player sees, with the HUD on top, and returns the
+// frame. The image is reused by the next call.
+func (r *Framebuffer) Render(w *World) *image.RGBA {
+	pix := r.image.Pix
+	for i := range pix {
+		pix[i] = 0
+	}
+	// bands only read the texture cache, so everything they might draw has
+	// to be in it before they start
+	if r.loaded != w {
+		r.LoadAllLevelTextures(w)
+	}
+	sky := r.getTexture(w.settings.sky)
+	sprites := r.sortSprites(w)
+
+	width := r.view.Width
+	if r.workers == 1 {
+		r.drawColumns(w, sky, sprites, 0, width)
+	} else {
+		bandWidth := (width + r.workers - 1) / r.workers
+		var wg sync.WaitGroup
+		for x0 := 0; x0 < width; x0 += bandWidth {
+			x1 := x0 + bandWidth
+			if x1 > width {
+				x1 = width
+			}
+			wg.Add(1)
+			go func(x0, x1 int) {
+				defer wg.Done()
+				r.drawColumns(w, sky, sprites, x0, x1)
+			}(x0, x1)
+		}
+		wg.Wait()
+	}
+
+	r.drawHud(w)
+	r.drawWeapon(w)
+	r.drawMiniMap(w)
+	return r.image
+}
+
+// drawColumns draws the view in the screen columns from x0 up to x1. Nothing
+// it draws reaches outside those columns, so bands can be drawn at once.
+func (r *Framebuffer) drawColumns(w *World, sky *texture, sprites []*sprite, x0, x1 int) {
+	r.drawSky(w, sky, x0, x1)
+
+	r.drawFloorAndCeiling(w, x0, x1)
+
+	for rayIndex := x0; rayIndex < x1; rayIndex++ {
+		// cameraX goes from -1 to +1 (very roughly)
+		cameraX := 2*(float64(rayIndex)/float64(r.view.Width)) - 1
+		ra := calculateRay(w, cameraX)
+		r.drawRay(w, ra, rayIndex)
+		r.zbuffer[rayIndex] = ra.distance
+	}
+
+	r.drawSprites(w, sprites, x0, x1)
+}
+
+func (r *Framebuffer) drawSky(w *World, sky *texture, x0, x1 int) {
+	angle := math.Atan2(w.player.dir.y, w.player.dir.x)
+	angle = (angle + (math.Pi)) / (2 * math.Pi)
+
+	// the sky spans twice the screen width, whatever size it's drawn at
+	var doubleWidth = sky.width
+	width, height := r.view.Width, r.view.Height
+
+	for x := x0; x < x1; x++ {
+		xoffset := x*sky.width/(2*width) + int(8*angle*float64(sky.width/2))
+		xoffset = xoffset % doubleWidth
+		if xoffset > doubleWidth {
+			xoffset -= doubleWidth
+		}
+		if xoffset < 0 {
+			xoffset += doubleWidth
+		}
+		for y := 0; y < height; y++ {
+			r.setPixel(x, y, sky.at(xoffset, y*sky.height/height))
+		}
+	}
+}
+
+// pixelScale is how many pixels square to draw each pixel of an image drawn
+// size times as big on the classic screen, so it keeps its size on others.
+func (r *Framebuffer) pixelScale(size int) int {
+	scale := int(float64(size*r.view.Height)/ScreenHeight + 0.5)
+	if scale < 1 {
+		scale = 1
+	}
+	return scale
+}
+
+func (r *Framebuffer) drawWeapon(w *World) {
+	// twice the size of its texture on the classic screen
+	scale := r.pixelScale(2)
+	frameSize := TextureWidth * 2
+	pos := vector{
+		x: float64(r.view.Width / 2),
+		y: float64(r.view.Height - frameSize*scale),
+	}
+	img := r.getTexture("weapon-staff")
+	r.drawScaledImage(pos, img, w.player.weaponAnimation.currentFrame, frameSize, scale)
+}
+
+func (r *Framebuffer) drawHud(w *World) {
+	scale := r.pixelScale(1)
+	ammoPos := vector{
+		x: float64(24 * scale),
+		y: float64(8 * scale),
+	}
+	ammoIcon := r.getTexture("ammo-icon")
+	r.drawScaledImage(ammoPos, ammoIcon, 0, TextureWidth, scale)
+
+	healthPos := vector{
+		x: float64(r.view.Width - (32+8)*scale),
+		y: float64(8 * scale),
+	}
+	healthIcon := r.getTexture("health-icon")
+	r.drawScaledImage(healthPos, healthIcon, 0, TextureWidth, scale)
+
+	RenderText(r.image, fmt.Sprintf("%d", w.player.ammo), int(ammoPos.x)+8*scale, 7*scale, scale)
+	RenderText(r.image, fmt.Sprintf("%d", w.player.health), int(healthPos.x)+8*scale, 7*scale, scale)
+
+	//RenderText(r.image, "find the portal to escape the maze!\nlots of love,\nbad wizard.", 32, 32, 1)
+}
+
+func (r *Framebuffer) drawScaledImage(pos vector, img *texture, frame int, textureWidth int, scale int) {
+	frameOffsetX := frame * textureWidth
+	px, py := int(pos.x), int(pos.y)
+	for x := 0; x < textureWidth; x++ {
+		for y := 0; y < img.height; y++ {
+			c := img.at(x+frameOffsetX, y)
+			if c.A == 0 {
+				continue
+			}
+			for q := x * scale; q < (x*scale)+scale; q++ {
+				for z := y * scale; z < (y*scale)+scale; z++ {
+					r.setPixel(q+px, z+py, c)
+				}
+			}
+		}
+	}
+}
+
+// sortSprites lists every sprite in the world, farthest first, with their
+// textures loaded.
+func (r *Framebuffer) sortSprites(w *World) []*sprite {
+	var sprites []*sprite
+
+	for _, e := range w.enemies {
+		sprites = append(sprites, e.entity.CurrentSprite())
+	}
+	for _, b := range w.bullets {
+		sprites = append(sprites, b.entity.CurrentSprite())
+	}
+	for _, b := range w.pickups {
+		sprites = append(sprites, b.entity.CurrentSprite())
+	}
+	for _, b := range w.effects {
+		sprites = append(sprites, b.entity.CurrentSprite())
+	}
+	for _, b := range w.portals {
+		sprites = append(sprites, b.entity.CurrentSprite())
+	}
+	for _, b := range w.scenery {
+		sprites = append(sprites, b.entity.CurrentSprite())
+	}
+	for _, b := range w.particles {
+		sprites = append(sprites, b.sprite)
+	}
+
+	for _, s := range sprites {
+		s.distance = (w.player.pos.x-s.pos.x)*(w.player.pos.x-s.pos.x) + (w.player.pos.y-s.pos.y)*(w.player.pos.y-s.pos.y)
+	}
+
+	sort.Slice(sprites, func(i, j int) bool {
+		return sprites[i].distance > sprites[j].distance
+	})
+
+	for _, s := range sprites {
+		r.getTexture(s.image)
+	}
+	return sprites
+}
+
+// drawSprites draws the stripes of the sprites that fall in the screen
+// columns from x0 up to x1.
+func (r *Framebuffer) drawSprites(w *World, sprites []*sprite, x0, x1 int) {
+	width, height := r.view.Width, r.view.Height
+	across, down := r.view.scale()
+	for _, s := range sprites {
+		spriteX := s.pos.x - w.player.pos.x
+		spriteY := s.pos.y - w.player.pos.y
+
+		//transform sprite with the inverse camera matrix
+		// [ planeX   dirX ] -1                                       [ dirY      -dirX ]
+		// [               ]       =  1/(planeX*dirY-dirX*planeY) *   [                 ]
+		// [ planeY   dirY ]                                          [ -planeY  planeX ]
+
+		invDet := 1.0 / (w.player.plane.x*w.player.dir.y - w.player.dir.x*w.player.plane.y) //required for correct matrix multiplication
+
+		transformX := invDet * (w.player.dir.y*spriteX - w.player.dir.x*spriteY)
+		transformY := invDet * (-w.player.plane.y*spriteX + w.player.plane.x*spriteY) //this is actually the depth inside the screen, that what Z is in 3D, the distance of sprite to player, matching sqrt(spriteDistance[i])
+
+		spriteScreenX := int((float64(width) / 2) * (1 + transformX/transformY))
+
+		//parameters for scaling and moving the sprites
+		var uDiv = 1.0
+		var vDiv = 1.0
+		// height is in pixels of the classic screen
+		var vMove = s.height * TextureHeight * down / ScreenHeight
+		vMoveScreen := int(vMove / transformY)
+
+		//calculate height of the sprite on screen
+		spriteHeight := int(math.Abs(down/(transformY)) / vDiv) //using "transformY" instead of the real distance prevents fisheye
+		//calculate lowest and highest pixel to fill in current stripe
+		drawStartY := (-spriteHeight/2 + height/2) + vMoveScreen
+		if drawStartY < 0 {
+			drawStartY = 0
+		}
+		drawEndY := (spriteHeight/2 + height/2) + vMoveScreen
+		if drawEndY >= height {
+			drawEndY = height - 1
+		}
+
+		//calculate width of the sprite
+		spriteWidth := int(math.Abs(across/(transformY)) / uDiv) // square in the world, so only as wide as it's high with square pixels
+		drawStartX := -spriteWidth/2 + spriteScreenX
+		if drawStartX < x0 {
+			drawStartX = x0
+		}
+		drawEndX := spriteWidth/2 + spriteScreenX
+		if drawEndX > x1 {
+			drawEndX = x1
+		}
+
+		img := r.cachedTexture(s.image)
+		frameOffsetX := 0
+		if s.animation != nil {
+			frameOffsetX = s.animation.currentFrame * TextureWidth
+		}
+
+		//loop through every vertical stripe of the sprite on screen
+		for stripe := drawStartX; stripe < drawEndX; stripe++ {
+			texX := int(256*(stripe-(-spriteWidth/2+spriteScreenX))*TextureWidth/spriteWidth) / 256
+			//the conditions in the if are:
+			//1) it's in front of camera plane so you don't see things behind you
+			//2) ZBuffer, with perpendicular distance
+			if transformY > 0 && transformY < r.zbuffer[stripe] {
+				for y := drawStartY; y < drawEndY; y++ { //for every pixel of the current stripe
+					d := (y-vMoveScreen)*256 - height*128 + spriteHeight*128 //256 and 128 factors to avoid floats
+					texY := ((d * TextureHeight) / spriteHeight) / 256
+					r.setPixel(stripe, y, img.at(texX+frameOffsetX, texY))
+				}
+			}
+		}
+
+	}
+}
+
+func (r *Framebuffer) drawRay(w *World, ray ray, index int) {
+	height := r.view.Height
+	_, down := r.view.scale()
+
+	lineHeight := (int)(down / ray.distance)
+
+	//calculate lowest and highest pixel to fill in current stripe
+	drawStart := height/2 - lineHeight/2
+	if drawStart < 0 {
+		drawStart = 0
+	}
+	drawEnd := height/2 + lineHeight/2
+	if drawEnd >= height {
+		drawEnd = height - 1
+	}
+
+	var texX = int(ray.wallX * TextureWidth)
+	//flip textures if looking in opposite direction
+	if ray.side == 0 && ray.dir.x > 0 {
+		texX = TextureWidth - texX - 1
+	}
+	if ray.side == 1 && ray.dir.y < 0 {
+		texX = TextureWidth - texX - 1
+	}
+	texture := missingWallTexture
+	if ray.texture != "" {
+		texture = ray.texture
+	}
+	img := r.cachedTexture(texture)
+
+	x := index
+	step := float64(TextureHeight) / float64(lineHeight)
+	texPos := float64(drawStart-height/2+lineHeight/2) * step
+
+	for y := drawStart; y < drawEnd; y++ {
+		texY := int(texPos) & (TextureHeight - 1)
+		texPos += step
+
+		rgba := shade(img.at(ray.flip.apply(texX, texY)), ray.distance, w.settings)
+		if ray.side == 0 {
+			rgba.R = rgba.R - (rgba.R / 3)
+			rgba.G = rgba.G - (rgba.G / 3)
+			rgba.B = rgba.B - (rgba.B / 3)
+		}
+		r.setPixel(x, y, rgba)
+	}
+}
+
+// shade applies the level's ambient light and fog to a texture colour seen
+// from distance away. Toggling fakeLightEnabled fades to black for levels
+// without fog of their own.
+func shade(c color.RGBA, distance float64, settings *levelSettings) color.RGBA {
+	if settings.ambientLight != 1 {
+		c.R = uint8(math.Min(float64(c.R)*settings.ambientLight, 255))
+		c.G = uint8(math.Min(float64(c.G)*settings.ambientLight, 255))
+		c.B = uint8(math.Min(float64(c.B)*settings.ambientLight, 255))
+	}
+
+	fogColor := settings.fogColor
+	fogDistance := settings.fogDistance
+	if fogDistance <= 0 {
+		if !fakeLightEnabled {
+			return c
+		}
+		const maxLightDistance = 10.0
+		fogColor = color.RGBA{}
+		fogDistance = maxLightDistance
+	}
+
+	amount := distance / fogDistance
+	if amount > 1 {
+		amount = 1
+	}
+
+	c.R = uint8(float64(c.R)*(1-amount) + float64(fogColor.R)*amount)
+	c.G = uint8(float64(c.G)*(1-amount) + float64(fogColor.G)*amount)
+	c.B = uint8(float64(c.B)*(1-amount) + float64(fogColor.B)*amount)
+
+	return c
+}
+
+// setPixel writes c at x, y unless it's fully transparent or off the frame.
+func (r *Framebuffer) setPixel(x int, y int, c color.RGBA) {
+	if c.A == 0 || x < 0 || y < 0 || x >= r.view.Width || y >= r.view.Height {
+		return
+	}
+	i := y*r.image.Stride + x*4
+	p := r.image.Pix[i : i+4 : i+4]
+	p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+}
+
+func (r *Framebuffer) drawFloorAndCeiling(w *World, x0, x1 int) {
+	width, height := r.view.Width, r.view.Height
+	_, down := r.view.scale()
+	for y := height / 2; y < height; y++ {
+		// rayDir for leftmost ray (x = 0) and rightmost ray (x = w)
+		rayDirX0 := w.player.dir.x - w.player.plane.x
+		rayDirY0 := w.player.dir.y - w.player.plane.y
+		rayDirX1 := w.player.dir.x + w.player.plane.x
+		rayDirY1 := w.player.dir.y + w.player.plane.y
+
+		// Current y position compared to the center of the screen (the horizon)
+		p := y - height/2 + 1
+
+		// Vertical position of the camera.
+		// NOTE: with 0.5, it's exactly in the center between floor and ceiling,
+		// matching also how the walls are being raycasted. For different values
+		// than 0.5, a separate loop must be done for ceiling and floor since
+		// they're no longer symmetrical.
+		posZ := 0.5 * down
+
+		// Horizontal distance from the camera to the floor for the current row.
+		// 0.5 is the z position exactly in the middle between floor and ceiling.
+		// NOTE: this is affine texture mapping, which is not perspective correct
+		// except for perfectly horizontal and vertical surfaces like the floor.
+		// NOTE: this formula is explained as follows: The camera ray goes through
+		// the following two points: the camera itself, which is at a certain
+		// height (posZ), and a point in front of the camera (through an imagined
+		// vertical plane containing the screen pixels) with horizontal distance
+		// 1 from the camera, and vertical position p lower than posZ (posZ - p). When going
+		// through that point, the line has vertically traveled by p units and
+		// horizontally by 1 unit. To hit the floor, it instead needs to travel by
+		// posZ units. It will travel the same ratio horizontally. The ratio was
+		// 1 / p for going through the camera plane, so to go posZ times farther
+		// to reach the floor, we get that the total horizontal distance is posZ / p.
+		rowDistance := posZ / float64(p)
+
+		// calculate the real world step vector we have to add for each x (parallel to camera plane)
+		// adding step by step avoids multiplications with a weight in the inner loop
+		floorStepX := rowDistance * (rayDirX1 - rayDirX0) / float64(width)
+		floorStepY := rowDistance * (rayDirY1 - rayDirY0) / float64(width)
+
+		// real world coordinates of the leftmost column. This will be updated as we step to the right.
+		floorX := w.player.pos.x + rowDistance*rayDirX0
+		floorY := w.player.pos.y + rowDistance*rayDirY0
+
+		// step across to the band rather than multiplying, so a band
+		// lands on the same coordinates as drawing the whole row would
+		for x := 0; x < x0; x++ {
+			floorX += floorStepX
+			floorY += floorStepY
+		}
+
+		for x := x0; x < x1; x++ {
+			// the cell coord is simply got from the integer parts of floorX and floorY
+			cellX := (int)(floorX)
+			cellY := (int)(floorY)
+
+			t := w.getTile(cellX, cellY)
+			floorTex := ""
+			ceilingTex := ""
+			var floorFlip, ceilingFlip textureFlip
+			if t != nil {
+				floorTex = t.floorTex
+				ceilingTex = t.ceilingTex
+				floorFlip = t.floorFlip
+				ceilingFlip = t.ceilingFlip
+			}
+
+			// get the texture coordinate from the fractional part
+			tx := (int)(TextureWidth*(floorX-float64(cellX))) & (TextureWidth - 1)
+			ty := (int)(TextureHeight*(floorY-float64(cellY))) & (TextureHeight - 1)
+
+			floorX += floorStepX
+			floorY += floorStepY
+
+			if floorTex != "" {
+				img := r.cachedTexture(w.texture(t, floorTex))
+				rgba := shade(img.at(floorFlip.apply(tx, ty)), rowDistance, w.settings)
+				r.setPixel(x, y, rgba)
+			}
+			if ceilingTex != "" {
+				img := r.cachedTexture(w.texture(t, ceilingTex))
+				rgba := shade(img.at(ceilingFlip.apply(tx, ty)), rowDistance, w.settings)
+				r.setPixel(x, height-y-1, rgba)
+			}
+
+		}
+
+	}
+}
+
+func (r *Framebuffer) drawMiniMap(w *World) {
+
+	if !w.player.showMiniMap {
+		return
+	}
+
+	const miniWidth = 32
+	const halfMiniWidth = miniWidth / 2
+
+	mapPlayerPos := mapPos{
+		x: int(w.player.pos.x),
+		y: int(w.player.pos.y),
+	}
+
+	// two pixels a tile on the classic screen, sized with the HUD on others
+	scale := r.pixelScale(1)
+	cell := 2 * scale
+	screenX, screenY := 8*scale, r.view.Height-40*scale
+
+	for tx := 0; tx < miniWidth; tx += 1 {
+		for ty := 0; ty < miniWidth; ty += 1 {
+			x := mapPlayerPos.x + tx - halfMiniWidth
+			y := mapPlayerPos.y + ty - halfMiniWidth
+
+			t := w.getTile(x, y)
+			if t == nil {
+				continue
+			}
+			if !t.isSeen() {
+				continue
+			}
+
+			c := emptyColor
+			if t.block {
+				c = blockColor
+			}
+			if t.door {
+				c = doorColor
+			}
+
+			r.drawChunkyPixel(screenX+x*cell, screenY+y*cell, cell, c)
+			if tx == halfMiniWidth && ty == halfMiniWidth {
+				r.drawChunkyPixel(screenX+x*cell, screenY+y*cell, cell, playerColor)
+			}
+		}
+	}
+}
+
+func (r *Framebuffer) drawChunkyPixel(x int, y int, size int, c color.RGBA) {
+	for dx := 0; dx < size; dx++ {
+		for dy := 0; dy < size; dy++ {
+			r.setPixel(x+dx, y+dy, c)
+		}
+	}
+}
+
+func (r *Framebuffer) getTexture(name string) *texture {
+	t, ok := r.textures[name]
+	if !ok {
+		t = newTexture(LoadImage(name + ".png"))
+		r.textures[name] = t
+	}
+	return t
+}
+
+// cachedTexture returns a texture that's already loaded, or the missing wall
+// texture if it isn't. Bands drawn at once use it, as they mustn't write the
+// cache.
+func (r *Framebuffer) cachedTexture(name string) *texture {
+	if t, ok := r.textures[name]; ok {
+		return t
+	}
+	return r.textures[missingWallTexture]
+}
+
+func (r *Framebuffer) cacheTexture(name string) {
+	r.getTexture(name)
+}
+
+func (r *Framebuffer) LoadAllLevelTextures(w *World) {
+	r.loaded = w
+	r.cacheTexture(missingWallTexture)
+	r.cacheTexture(w.settings.sky)
+	for _, outsideTile := range w.tiles {
+		for _, t := range outsideTile {
+			for _, name := range []string{t.wallTex, t.wallTexN, t.wallTexS, t.wallTexE, t.wallTexW, t.doorTex, t.floorTex, t.ceilingTex} {
+				if name != "" {
+					r.cacheTexture(name)
+				}
+			}
+			for _, a := range t.animations {
+				for _, frame := range a.frames {
+					r.cacheTexture(frame)
+				}
+			}
+		}
+	}
+	for _, s := range w.scenery {
+		for _, sp := range s.entity.sprites {
+			r.cacheTexture(sp.image)
+		}
+	}
+	for _, e := range w.enemies {
+		for _, sp := range e.entity.sprites {
+			r.cacheTexture(sp.image)
+		}
+	}
+	for _, s := range w.particles {
+		r.cacheTexture(s.sprite.image)
+	}
+	for _, e := range w.pickups {
+		for _, sp := range e.entity.sprites {
+			r.cacheTexture(sp.image)
+		}
+	}
+	for _, e := range w.effects {
+		for _, sp := range e.entity.sprites {
+			r.cacheTexture(sp.image)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package raycast
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"testing"
+)
+
+// TestRenderWorkers checks that drawing in bands on several goroutines gives
+// the same frame as drawing it on one, byte for byte. Run it with -race to
+// check the bands don't share anything they write.
+func TestRenderWorkers(t *testing.T) {
+	for _, name := range []string{"classic", "widescreen", "retro"} {
+		for _, workers := range []int{2, 3, 8} {
+			name, workers := name, workers
+			t.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(t *testing.T) {
+				one := newTestRender(t, Views[name], 1)
+				many := newTestRender(t, Views[name], workers)
+				for i := 0; i < 16; i++ {
+					angle := 2 * math.Pi * float64(i) / 16
+					want := one.render(angle)
+					got := many.render(angle)
+					if !bytes.Equal(got.Pix, want.Pix) {
+						t.Errorf("frame at %.0f degrees differs from the one drawn by 1 worker", angle*180/math.Pi)
+					}
+				}
+			})
+		}
+	}
+}
+
+// testRender draws the library level with the mini map on, from where it
+// starts, in any direction.
+type testRender struct {
+	w    *World
+	f    *Framebuffer
+	x, y float64
+}
+
+func newTestRender(t *testing.T, v View, workers int) *testRender {
+	w, err := NewWorldFS(os.DirFS("res/maps"), "library.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetMiniMap(true)
+	f := NewFramebuffer()
+	if err := f.SetView(v); err != nil {
+		t.Fatal(err)
+	}
+	f.SetWorkers(workers)
+	f.SetWorld(w)
+	x, y, _, _ := w.Camera()
+	return &testRender{w: w, f: f, x: x, y: y}
+}
+
+func (r *testRender) render(angle float64) *image.RGBA {
+	r.w.SetCamera(r.x, r.y, math.Cos(angle), math.Sin(angle))
+	return r.f.Render(r.w)
+}
+
+// BenchmarkFramebufferRender times drawing a frame of the library level in
+// each view, turning the camera a little each frame so every direction is
+// drawn:
+//
+//	go test -run NONE -bench FramebufferRender
+func BenchmarkFramebufferRender(b *testing.B) {
+	for _, name := range []string{"classic", "widescreen", "retro"} {
+		for _, workers := range []int{1, 4} {
+			name, workers := name, workers
+			b.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(b *testing.B) {
+				w, err := NewWorldFS(os.DirFS("res/maps"), "library.json")
+				if err != nil {
+					b.Fatal(err)
+				}
+				v := Views[name]
+				f := NewFramebuffer()
+				if err := f.SetView(v); err != nil {
+					b.Fatal(err)
+				}
+				f.SetWorkers(workers)
+				f.SetWorld(w)
+				// one frame first so textures loaded on first use aren't timed
+				f.Render(w)
+				x, y, _, _ := w.Camera()
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					angle := 2 * math.Pi * float64(i%360) / 360
+					w.SetCamera(x, y, math.Cos(angle), math.Sin(angle))
+					f.Render(w)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkImageRender times the same frames as BenchmarkFramebufferRender
+// drawn the way the framebuffer did before it wrote raw bytes, as a baseline
+// to compare with:
+//
+//	go test -run NONE -bench 'Render$'
+//
+// It only draws the sky, walls, floor and ceiling, leaving out the sprites
+// and HUD, so if anything it flatters the baseline.
+func BenchmarkImageRender(b *testing.B) {
+	for _, name := range []string{"classic", "widescreen", "retro"} {
+		name := name
+		b.Run(name, func(b *testing.B) {
+			w, err := NewWorldFS(os.DirFS("res/maps"), "library.json")
+			if err != nil {
+				b.Fatal(err)
+			}
+			f := NewFramebuffer()
+			if err := f.SetView(Views[name]); err != nil {
+				b.Fatal(err)
+			}
+			f.SetWorld(w)
+			r := newImageRender(Views[name])
+			r.render(w)
+			x, y, _, _ := w.Camera()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				angle := 2 * math.Pi * float64(i%360) / 360
+				w.SetCamera(x, y, math.Cos(angle), math.Sin(angle))
+				r.render(w)
+			}
+		})
+	}
+}
+
+// imageRender draws through image.Image: each texel is read with At and
+// converted to RGBA, and each pixel written with Set through color.Color.
+type imageRender struct {
+	view     View
+	image    *image.RGBA
+	textures map[string]image.Image
+}
+
+func newImageRender(v View) *imageRender {
+	return &imageRender{
+		view:     v,
+		image:    image.NewRGBA(image.Rect(0, 0, v.Width, v.Height)),
+		textures: map[string]image.Image{},
+	}
+}
+
+func (r *imageRender) texture(name string) image.Image {
+	img, ok := r.textures[name]
+	if !ok {
+		img = LoadImage(name + ".png")
+		r.textures[name] = img
+	}
+	return img
+}
+
+func (r *imageRender) setPixel(x int, y int, c color.Color) {
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return
+	}
+	r.image.Set(x, y, c)
+}
+
+func (r *imageRender) render(w *World) {
+	for i := range r.image.Pix {
+		r.image.Pix[i] = 0
+	}
+	width, height := r.view.Width, r.view.Height
+	_, down := r.view.scale()
+
+	sky := r.texture(w.settings.sky)
+	skyWidth, skyHeight := sky.Bounds().Dx(), sky.Bounds().Dy()
+	angle := (math.Atan2(w.player.dir.y, w.player.dir.x) + math.Pi) / (2 * math.Pi)
+	for x := 0; x < width; x++ {
+		xoffset := (x*skyWidth/(2*width) + int(8*angle*float64(skyWidth/2))) % skyWidth
+		if xoffset < 0 {
+			xoffset += skyWidth
+		}
+		for y := 0; y < height; y++ {
+			r.setPixel(x, y, sky.At(xoffset, y*skyHeight/height))
+		}
+	}
+
+	for y := height / 2; y < height; y++ {
+		rayDirX0 := w.player.dir.x - w.player.plane.x
+		rayDirY0 := w.player.dir.y - w.player.plane.y
+		rayDirX1 := w.player.dir.x + w.player.plane.x
+		rayDirY1 := w.player.dir.y + w.player.plane.y
+		rowDistance := 0.5 * down / float64(y-height/2+1)
+		floorStepX := rowDistance * (rayDirX1 - rayDirX0) / float64(width)
+		floorStepY := rowDistance * (rayDirY1 - rayDirY0) / float64(width)
+		floorX := w.player.pos.x + rowDistance*rayDirX0
+		floorY := w.player.pos.y + rowDistance*rayDirY0
+		for x := 0; x < width; x++ {
+			cellX, cellY := int(floorX), int(floorY)
+			tx := int(TextureWidth*(floorX-float64(cellX))) & (TextureWidth - 1)
+			ty := int(TextureHeight*(floorY-float64(cellY))) & (TextureHeight - 1)
+			floorX += floorStepX
+			floorY += floorStepY
+			t := w.getTile(cellX, cellY)
+			if t == nil {
+				continue
+			}
+			if t.floorTex != "" {
+				c := r.texture(w.texture(t, t.floorTex)).At(t.floorFlip.apply(tx, ty))
+				r.setPixel(x, y, shade(color.RGBAModel.Convert(c).(color.RGBA), rowDistance, w.settings))
+			}
+			if t.ceilingTex != "" {
+				c := r.texture(w.texture(t, t.ceilingTex)).At(t.ceilingFlip.apply(tx, ty))
+				r.setPixel(x, height-y-1, shade(color.RGBAModel.Convert(c).(color.RGBA), rowDistance, w.settings))
+			}
+		}
+	}
+
+	for x := 0; x < width; x++ {
+		ray := calculateRay(w, 2*(float64(x)/float64(width))-1)
+		lineHeight := int(down / ray.distance)
+		drawStart := height/2 - lineHeight/2
+		if drawStart < 0 {
+			drawStart = 0
+		}
+		drawEnd := height/2 + lineHeight/2
+		if drawEnd >= height {
+			drawEnd = height - 1
+		}
+		texX := int(ray.wallX * TextureWidth)
+		if (ray.side == 0 && ray.dir.x > 0) || (ray.side == 1 && ray.dir.y < 0) {
+			texX = TextureWidth - texX - 1
+		}
+		name := missingWallTexture
+		if ray.texture != "" {
+			name = ray.texture
+		}
+		img := r.texture(name)
+		step := float64(TextureHeight) / float64(lineHeight)
+		texPos := float64(drawStart-height/2+lineHeight/2) * step
+		for y := drawStart; y < drawEnd; y++ {
+			texY := int(texPos) & (TextureHeight - 1)
+			texPos += step
+			rgba := shade(color.RGBAModel.Convert(img.At(ray.flip.apply(texX, texY))).(color.RGBA), ray.distance, w.settings)
+			if ray.side == 0 {
+				rgba.R -= rgba.R / 3
+				rgba.G -= rgba.G / 3
+				rgba.B -= rgba.B / 3
+			}
+			r.setPixel(x, y, rgba)
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package raycast
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"raycast.com/tiledgrid"
 )
 
 const (
@@ -12,15 +19,20 @@ const (
 	ScreenWidth   = 256
 	ScreenHeight  = 256
 	PlayerWidth   = 4
-	GlobalScale   = 1
-	NumRays       = 256
 	TextureWidth  = 32
 	TextureHeight = 32
 )
 
+// ErrLevelComplete is returned from Update once the player reaches the end
+// of the level.
+var ErrLevelComplete = errors.New("level complete")
+
 type Game struct {
-	world            *World
+	world *World
+	// world file the current map is part of, if the level was loaded from one
+	tiledWorld       *tiledgrid.TiledWorld
 	renderer         *Renderer
+	view             View
 	lastUpdateCalled time.Time
 }
 
@@ -28,10 +40,26 @@ func NewGame() *Game {
 	return &Game{
 		//world:            NewWorld("stars-path.json"),
 		renderer:         NewRenderer(),
+		view:             DefaultView(),
 		lastUpdateCalled: time.Now(),
 	}
 }
 
+// SetView changes the internal resolution, aspect ratio and field of view
+// the game is drawn with.
+func (g *Game) SetView(v View) error {
+	if err := g.renderer.SetView(v); err != nil {
+		return err
+	}
+	g.view = v
+	return nil
+}
+
+// SetRenderWorkers sets how many goroutines draw each frame.
+func (g *Game) SetRenderWorkers(n int) {
+	g.renderer.frame.SetWorkers(n)
+}
+
 func (g *Game) Update() error {
 	delta := time.Now().Sub(g.lastUpdateCalled).Milliseconds()
 	g.lastUpdateCalled = time.Now()
@@ -39,6 +67,12 @@ func (g *Game) Update() error {
 	if err != nil {
 		return err
 	}
+	if g.world.complete {
+		return ErrLevelComplete
+	}
+	if g.world.exit != nil {
+		return g.travel(g.world.exit)
+	}
 	return nil
 }
 
@@ -49,10 +83,83 @@ func (g *Game) Draw(screen *ebiten.Image) {
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return ScreenWidth, ScreenHeight
+	return g.view.screenSize()
+}
+
+// LoadLevel starts a level from a map, or from a Tiled world file, in which
+// case the player starts in the first of its maps that has a start object.
+func (g *Game) LoadLevel(level string) error {
+	g.tiledWorld = nil
+	if strings.HasSuffix(level, tiledgrid.WorldExt) {
+		tw, err := tiledgrid.LoadWorld(os.DirFS(mapDirectory), level)
+		if err != nil {
+			return err
+		}
+		level = tw.Maps[0].FileName
+		for _, m := range tw.Maps {
+			l, err := LoadLevel(m.FileName)
+			if err != nil {
+				return err
+			}
+			if l.objectData.hasStart {
+				level = m.FileName
+				break
+			}
+		}
+		g.tiledWorld = tw
+	}
+
+	var w *World
+	var err error
+	if g.world != nil {
+		w, err = newWorld(level, g.world.soundPlayer)
+	} else {
+		w, err = NewWorld(level)
+	}
+	if err != nil {
+		return err
+	}
+	g.world = w
+	g.renderer.SetWorld(g.world)
+	return nil
+}
+
+// travel takes the player through an exit into another map, carrying over
+// their ammo, health, keys and souls.
+func (g *Game) travel(exit *levelExit) error {
+	from := g.world
+	dest := path.Join(path.Dir(from.mapFile), exit.mapFile)
+	w, err := newWorld(dest, from.soundPlayer)
+	if err != nil {
+		return fmt.Errorf("exit from %s: %w", from.mapFile, err)
+	}
+
+	switch {
+	case exit.spawn != "":
+		spawn, ok := w.spawns[exit.spawn]
+		if !ok {
+			return fmt.Errorf("exit from %s: %s has no spawn %q", from.mapFile, dest, exit.spawn)
+		}
+		w.player.pos = spawn.pos
+		w.player.face(spawn.facing)
+	case g.tiledWorld != nil && g.tiledWorld.Map(from.mapFile) != nil && g.tiledWorld.Map(dest) != nil:
+		// stitched maps: keep the player where they are in the world,
+		// inside the edge of the new map
+		here, there := g.tiledWorld.Map(from.mapFile), g.tiledWorld.Map(dest)
+		w.player.pos = vector{
+			x: clamp(from.player.pos.x+(here.X-there.X)/GridTileSize, 0.5, float64(w.width)-0.5),
+			y: clamp(from.player.pos.y+(here.Y-there.Y)/GridTileSize, 0.5, float64(w.height)-0.5),
+		}
+		w.player.face(from.player.dir)
+	}
+	w.player.carry(from.player)
+	w.armPortals()
+
+	g.world = w
+	g.renderer.SetWorld(g.world)
+	return nil
 }
 
-func (g *Game) LoadLevel(level string) {
-	g.world = NewWorld(level)
-	g.renderer.LoadAllLevelTextures(g.world)
+func clamp(v float64, min float64, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
 }
@@ -0,0 +1,226 @@
+package raycast
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"raycast.com/tiledgrid"
+)
+
+// Golden image tests render fixed views of a test level offscreen and
+// compare them with the images checked in next to it, to catch changes to
+// how the game draws. To accept a change, write the renders as the new
+// golden images:
+//
+//	go test -run Golden -update
+//	go test -run Golden/door -update
+//
+// A failing view has its render and a diff image written to -out, with the
+// pixels that changed in red over a faded copy of the golden image.
+var (
+	update    = flag.Bool("update", false, "write golden image renders as the new golden images")
+	goldenOut = flag.String("out", filepath.Join(os.TempDir(), "raycast-golden"), "directory to write renders and diffs of failing golden views to")
+)
+
+const (
+	goldenDir = "testdata/golden"
+	// largest difference in any colour channel that still counts as the same
+	// pixel
+	goldenTolerance = 16
+	// percentage of pixels allowed to differ by more than goldenTolerance
+	goldenMaxDiff = 0.5
+)
+
+// goldenScenario is a view of a level to check against its golden image.
+type goldenScenario struct {
+	name    string
+	mapFile string
+	// camera position and the direction it looks in
+	x, y       float64
+	dirX, dirY float64
+	miniMap    bool
+	// render a full turn first so the mini map has seen the room
+	lookAround bool
+	// one of Views to draw with, classic if empty
+	view string
+}
+
+var goldenScenarios = []goldenScenario{
+	{name: "door-angle", mapFile: "golden.txt", x: 8.5, y: 6.5, dirX: -1, dirY: -0.6},
+	{name: "sprite-behind-wall", mapFile: "golden.txt", x: 14.6, y: 6.5, dirX: -2.1, dirY: -5},
+	{name: "floor-horizon", mapFile: "golden.txt", x: 1.5, y: 6.5, dirX: 1, dirY: 0},
+	{name: "minimap", mapFile: "golden.txt", x: 9.5, y: 3.5, dirX: 1, dirY: 0.3, miniMap: true, lookAround: true},
+	{name: "widescreen", mapFile: "golden.txt", x: 14.6, y: 6.5, dirX: -2.1, dirY: -5, view: "widescreen"},
+	{name: "retro", mapFile: "golden.txt", x: 9.5, y: 3.5, dirX: 1, dirY: 0.3, miniMap: true, lookAround: true, view: "retro"},
+}
+
+func TestGolden(t *testing.T) {
+	for _, s := range goldenScenarios {
+		s := s
+		t.Run(s.name, func(t *testing.T) {
+			got, err := s.render(os.DirFS(goldenDir), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			goldenFile := filepath.Join(goldenDir, s.name+".png")
+			if *update {
+				if err := writePNG(goldenFile, got); err != nil {
+					t.Fatal(err)
+				}
+				t.Logf("updated %s", goldenFile)
+				return
+			}
+
+			want, err := readPNG(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff, n := compareImages(want, got, goldenTolerance)
+			allowed := int(goldenMaxDiff / 100 * float64(got.Bounds().Dx()*got.Bounds().Dy()))
+			if n > allowed {
+				t.Errorf("%d pixels differ, %d allowed", n, allowed)
+				writeFailure(t, s.name, got, diff)
+			}
+		})
+	}
+}
+
+// writeFailure saves what was drawn for a failing view and its diff image.
+func writeFailure(t *testing.T, name string, got image.Image, diff image.Image) {
+	if err := os.MkdirAll(*goldenOut, 0755); err != nil {
+		t.Log(err)
+		return
+	}
+	gotFile := filepath.Join(*goldenOut, name+".png")
+	diffFile := filepath.Join(*goldenOut, name+"-diff.png")
+	if err := writePNG(gotFile, got); err != nil {
+		t.Log(err)
+		return
+	}
+	if err := writePNG(diffFile, diff); err != nil {
+		t.Log(err)
+		return
+	}
+	t.Logf("wrote %s and %s", gotFile, diffFile)
+}
+
+// render draws the scenario's view with the given number of workers, without
+// running the world, so enemies and animations stay on their first frame.
+func (s goldenScenario) render(fsys fs.FS, workers int) (*image.RGBA, error) {
+	w, err := NewWorldFS(fsys, s.mapFile)
+	if err != nil {
+		return nil, err
+	}
+	f := NewFramebuffer()
+	f.SetWorkers(workers)
+	if s.view != "" {
+		v, ok := Views[s.view]
+		if !ok {
+			return nil, fmt.Errorf("no view %q", s.view)
+		}
+		if err := f.SetView(v); err != nil {
+			return nil, err
+		}
+	}
+	f.SetWorld(w)
+	if s.lookAround {
+		for i := 0; i < 8; i++ {
+			angle := float64(i) * math.Pi / 4
+			w.SetCamera(s.x, s.y, math.Cos(angle), math.Sin(angle))
+			f.Render(w)
+		}
+	}
+	w.SetCamera(s.x, s.y, s.dirX, s.dirY)
+	w.SetMiniMap(s.miniMap)
+	return overBlack(f.Render(w)), nil
+}
+
+// overBlack returns the frame as the window shows it, drawn over a black
+// screen. Frames hold premultiplied colours such as the mini map's, which
+// would not survive being saved as a PNG with their alpha.
+func overBlack(frame *image.RGBA) *image.RGBA {
+	img := image.NewRGBA(frame.Bounds())
+	copy(img.Pix, frame.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// compareImages counts the pixels of got that differ from want by more than
+// tolerance in any channel, and draws them in red over a faded grey copy of
+// want.
+func compareImages(want image.Image, got *image.RGBA, tolerance int) (*image.RGBA, int) {
+	bounds := got.Bounds()
+	diff := image.NewRGBA(bounds)
+	n := 0
+	if want.Bounds() != bounds {
+		// nothing lines up, so every pixel counts as changed
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				diff.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+		return diff, bounds.Dx() * bounds.Dy()
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			g := got.RGBAAt(x, y)
+			if channelDiff(w, g) > tolerance {
+				n++
+				diff.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+				continue
+			}
+			grey := uint8((int(w.R) + int(w.G) + int(w.B)) / 3 / 4)
+			diff.SetRGBA(x, y, color.RGBA{R: grey, G: grey, B: grey, A: 255})
+		}
+	}
+	return diff, n
+}
+
+func channelDiff(a color.RGBA, b color.RGBA) int {
+	most := 0
+	for _, d := range []int{
+		int(a.R) - int(b.R),
+		int(a.G) - int(b.G),
+		int(a.B) - int(b.B),
+		int(a.A) - int(b.A),
+	} {
+		if d < 0 {
+			d = -d
+		}
+		if d > most {
+			most = d
+		}
+	}
+	return most
+}
+
+func readPNG(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return img, nil
+}
+
+func writePNG(name string, img image.Image) error {
+	return tiledgrid.WriteFile(name, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
@@ -1,39 +1,147 @@
 package raycast
 
-import "raycast.com/tiledgrid"
+import (
+	"fmt"
+	"image/color"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+
+	"raycast.com/tiledgrid"
+)
+
+// directory LoadLevel finds maps in
+const mapDirectory = "res/maps"
 
 type level struct {
+	settings   *levelSettings
 	objectData *objectData
 	tiles      [][]*tile
 	width      int
 	height     int
 }
 
-func LoadLevel(fileName string) *level {
-	grid := tiledgrid.NewTileGrid(fileName)
+func LoadLevel(fileName string) (*level, error) {
+	if strings.HasSuffix(fileName, TextLevelExt) {
+		return LoadTextLevel(os.DirFS(mapDirectory), fileName)
+	}
+	grid, err := tiledgrid.NewTileGrid(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return newLevel(grid)
+}
+
+// LoadLevelFS loads a level from a map file in fsys. Files ending in
+// TextLevelExt are read as text levels.
+func LoadLevelFS(fsys fs.FS, fileName string) (*level, error) {
+	if strings.HasSuffix(fileName, TextLevelExt) {
+		return LoadTextLevel(fsys, fileName)
+	}
+	grid, err := tiledgrid.LoadTileGrid(fsys, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return newLevel(grid)
+}
+
+func newLevel(grid *tiledgrid.TiledGrid) (*level, error) {
+	settings, err := loadLevelSettings(grid)
+	if err != nil {
+		return nil, err
+	}
+	objData, err := loadObjectData(grid)
+	if err != nil {
+		return nil, err
+	}
 	return &level{
+		settings:   settings,
 		tiles:      loadTiles(grid),
-		objectData: loadObjectData(grid),
-		width:      grid.Layers[0].Width,
-		height:     grid.Layers[0].Height,
+		objectData: objData,
+		width:      grid.Width,
+		height:     grid.Height,
+	}, nil
+}
+
+// levelSettings are the map properties that change how a whole level looks
+// and sounds.
+type levelSettings struct {
+	name string
+	// texture drawn behind everything, scrolling as the player turns
+	sky string
+	// walls, floors and ceilings fade to fogColor at fogDistance, 0 for no fog
+	fogColor    color.RGBA
+	fogDistance float64
+	// brightness of walls, floors and ceilings, 1 leaves textures unchanged
+	ambientLight float64
+	music        string
+	// seconds a good player needs to finish the level
+	parTime float64
+	// seconds an open door waits before closing, 0 to leave doors open
+	doorCloseDelay float64
+}
+
+func defaultLevelSettings() *levelSettings {
+	return &levelSettings{
+		sky:            "background",
+		ambientLight:   1,
+		doorCloseDelay: defaultDoorCloseDelay,
 	}
 }
 
+func loadLevelSettings(grid *tiledgrid.TiledGrid) (*levelSettings, error) {
+	var err error
+	s := defaultLevelSettings()
+	props := grid.Properties
+	if s.name, err = props.String("name", s.name); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.sky, err = props.String("sky", s.sky); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.fogColor, err = props.Color("fogColor", s.fogColor); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.fogDistance, err = props.Float("fogDistance", s.fogDistance); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.ambientLight, err = props.Float("ambientLight", s.ambientLight); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.music, err = props.String("music", s.music); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.parTime, err = props.Float("parTime", s.parTime); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	if s.doorCloseDelay, err = props.Float("doorCloseDelay", s.doorCloseDelay); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	return s, nil
+}
+
+// names of the tile layers that are combined into a single tile per cell
+const (
+	floorLayerName   = "floor"
+	wallLayerName    = "walls"
+	ceilingLayerName = "ceiling"
+)
+
 func loadTiles(grid *tiledgrid.TiledGrid) [][]*tile {
-	tilesRow := make([][]*tile, grid.Layers[0].Width)
-	for ix := 0; ix < grid.Layers[0].Width; ix++ {
-		tilesColumn := make([]*tile, grid.Layers[0].Height)
-		for iy := 0; iy < grid.Layers[0].Height; iy++ {
-			td := grid.GetTileData(ix, iy)
-			tilesColumn[iy] = &tile{
-				block:      td.Block,
-				door:       td.Door,
-				north:      td.North,
-				floorTex:   td.FloorTex,
-				wallTex:    td.WallTex,
-				doorTex:    td.DoorTex,
-				ceilingTex: td.CeilingTex,
-				locked:     td.Locked,
+	hasNamedLayers := grid.TileLayer(floorLayerName) != nil ||
+		grid.TileLayer(wallLayerName) != nil ||
+		grid.TileLayer(ceilingLayerName) != nil
+
+	animations := tileAnimations{}
+	tilesRow := make([][]*tile, grid.Width)
+	for ix := 0; ix < grid.Width; ix++ {
+		tilesColumn := make([]*tile, grid.Height)
+		for iy := 0; iy < grid.Height; iy++ {
+			if hasNamedLayers {
+				tilesColumn[iy] = combineLayerTiles(grid, animations, ix, iy)
+			} else {
+				tilesColumn[iy] = newTile(grid.GetTileData(ix, iy), animations)
 			}
 		}
 		tilesRow[ix] = tilesColumn
@@ -42,117 +150,174 @@ func loadTiles(grid *tiledgrid.TiledGrid) [][]*tile {
 	return tilesRow
 }
 
+func newTile(td *tiledgrid.TileData, animations tileAnimations) *tile {
+	flip := newTextureFlip(td.Flip)
+	t := &tile{
+		block:       td.Block,
+		door:        td.Door,
+		north:       td.North,
+		floorTex:    td.FloorTex,
+		wallTex:     td.WallTex,
+		wallTexN:    td.WallTexN,
+		wallTexS:    td.WallTexS,
+		wallTexE:    td.WallTexE,
+		wallTexW:    td.WallTexW,
+		doorTex:     td.DoorTex,
+		ceilingTex:  td.CeilingTex,
+		locked:      td.Locked,
+		wallFlip:    flip,
+		floorFlip:   flip,
+		ceilingFlip: flip,
+		animations:  animations.of(td),
+	}
+	if t.door && !t.block {
+		t.doorOpen = 1
+		t.doorState = doorOpen
+	}
+	return t
+}
+
+// combineLayerTiles builds a cell from the walls layer, with the floor and
+// ceiling textures painted on their own layers taking precedence.
+func combineLayerTiles(grid *tiledgrid.TiledGrid, animations tileAnimations, x, y int) *tile {
+	var t *tile
+	if grid.TileLayer(wallLayerName) != nil {
+		t = newTile(grid.GetLayerTileData(wallLayerName, x, y), animations)
+	} else {
+		t = newTile(grid.GetTileData(x, y), animations)
+	}
+	if floor := grid.GetLayerTileData(floorLayerName, x, y); floor.FloorTex != "" {
+		t.floorTex = floor.FloorTex
+		t.floorFlip = newTextureFlip(floor.Flip)
+		t.setAnimation(floor.FloorTex, animations.of(floor)[floor.FloorTex])
+	}
+	if ceiling := grid.GetLayerTileData(ceilingLayerName, x, y); ceiling.CeilingTex != "" {
+		t.ceilingTex = ceiling.CeilingTex
+		t.ceilingFlip = newTextureFlip(ceiling.Flip)
+		t.setAnimation(ceiling.CeilingTex, animations.of(ceiling)[ceiling.CeilingTex])
+	}
+	return t
+}
+
+func newTextureFlip(f tiledgrid.Flip) textureFlip {
+	return textureFlip{
+		horizontal: f.Horizontal,
+		vertical:   f.Vertical,
+		diagonal:   f.Diagonal,
+	}
+}
+
 type objectData struct {
-	startPos vector
-	startDir string
-	enemies  []*enemy
-	pickups  []*pickup
-	scenery  []*scenery
-	portals  []*portal
+	hasStart    bool
+	startPos    vector
+	startDir    string
+	startFacing vector
+	enemies     []*enemy
+	pickups     []*pickup
+	scenery     []*scenery
+	portals     []*portal
+	// where exits from other maps can place the player, by name
+	spawns map[string]placement
+	// objects with a type or name the loader does not know about
+	unknown []*tiledgrid.ObjectData
 }
 
-func loadObjectData(grid *tiledgrid.TiledGrid) *objectData {
+func loadObjectData(grid *tiledgrid.TiledGrid) (*objectData, error) {
+	defs, err := loadedEntityDefinitions()
+	if err != nil {
+		return nil, err
+	}
 	objData := &objectData{
 		enemies: []*enemy{},
 		pickups: []*pickup{},
 		scenery: []*scenery{},
 		portals: []*portal{},
+		spawns:  map[string]placement{},
 	}
 
-	const GridTileSize = 16
-	const halfMapTile = 0.5
 	objects := grid.GetObjectData()
 
 	for _, obj := range objects {
-		pos := vector{
-			x: float64(obj.X/GridTileSize) + halfMapTile,
-			y: float64(obj.Y/GridTileSize) + halfMapTile,
-		}
+		pl := newPlacement(obj)
+		pos := pl.pos
 		switch obj.ObjectType {
 		case "level":
-			if obj.Name == "start" {
+			switch obj.Name {
+			case "start":
+				dir, err := getStringProperty("dir", obj, "")
+				if err != nil {
+					return nil, err
+				}
+				objData.hasStart = true
 				objData.startPos = pos
-				objData.startDir = getStringProperty("dir", obj)
-			}
-			if obj.Name == "end" {
-				objData.portals = append(objData.portals, NewPortal(pos))
-			}
-			break
-		case "scenery":
-			if obj.Name == "candlestick" {
-				s := NewAnimatedSprite("candlestick", &animation{
-					numFrames: 4,
-					numTime:   0.2 * 1000,
-					isLoop:    true,
-				})
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, sceneryDestroyedEffectType, "enemy-hurt", "", true, true))
-			}
-			if obj.Name == "barrel" {
-				s := NewSprite("barrel")
-				s.height = 0.5
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, explosionEffectType, "enemy-die", "", true, true))
-			}
-			if obj.Name == "tree" {
-				s := NewSprite("tree")
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, explosionEffectType, "thud", "", false, true))
-			}
-			if obj.Name == "bush" {
-				s := NewSprite("bush")
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, explosionEffectType, "thud", "", false, true))
-			}
-			if obj.Name == "web" {
-				s := NewSprite("web")
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, sceneryDestroyedEffectType, "enemy-hurt", "", false, false))
-			}
-			if obj.Name == "rock" {
-				s := NewSprite("scenery-rock-1")
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, sceneryDestroyedEffectType, "enemy-hurt", "", false, false))
+				objData.startDir = dir
+				objData.startFacing = pl.facing
+			case "end":
+				objData.addPortal(NewPortal(pos), pl)
+			default:
+				objData.unknown = append(objData.unknown, obj)
 			}
-			if obj.Name == "pyramid" {
-				s := NewSprite("pyramid")
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, sceneryDestroyedEffectType, "enemy-hurt", "", false, false))
+		case "exit":
+			mapFile, err := getFileProperty("map", obj, "")
+			if err != nil {
+				return nil, err
 			}
-			if obj.Name == "lamp" {
-				s := NewAnimatedSprite("lamp", &animation{
-					numFrames: 4,
-					numTime:   0.2 * 1000,
-					isLoop:    true,
-				})
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, sceneryDestroyedEffectType, "crack", "", false, false))
+			if mapFile == "" {
+				return nil, fmt.Errorf("object %q: exit has no map", obj.Name)
 			}
-			if obj.Name == "candlebra" {
-				s := NewSprite("candlebra")
-				objData.scenery = append(objData.scenery, NewScenery(s, pos, sceneryDestroyedEffectType, "crack", "", false, false))
+			spawn, err := getStringProperty("spawn", obj, "")
+			if err != nil {
+				return nil, err
 			}
-			break
-		case "enemy":
-			if obj.Name == "ball" {
-				objData.enemies = append(objData.enemies, NewEnemy(ballEnemyType, pos))
+			objData.addPortal(NewExit(pos, &levelExit{mapFile: mapFile, spawn: spawn}), pl)
+		case "spawn":
+			if _, ok := objData.spawns[obj.Name]; ok {
+				return nil, fmt.Errorf("object %q: more than one spawn with this name", obj.Name)
 			}
-			if obj.Name == "blue" {
-				objData.enemies = append(objData.enemies, NewEnemy(blueEnemyType, pos))
+			dir, err := getStringProperty("dir", obj, "")
+			if err != nil {
+				return nil, err
 			}
-			if obj.Name == "blob" {
-				objData.enemies = append(objData.enemies, NewEnemy(blobEnemyType, pos))
+			if dir != "" {
+				facing, ok := dirVector(dir)
+				if !ok {
+					return nil, fmt.Errorf("object %q: dir %q is not one of north, south, east or west", obj.Name, dir)
+				}
+				pl.facing = facing
 			}
-			if obj.Name == "alien" {
-				objData.enemies = append(objData.enemies, NewEnemy(alienEnemyType, pos))
+			objData.spawns[obj.Name] = pl
+		default:
+			def := defs.lookup(obj.ObjectType, obj.Name)
+			if def == nil {
+				objData.unknown = append(objData.unknown, obj)
+				continue
 			}
-			break
-		case "pickup":
-			if obj.Name == "ammo" {
-				objData.pickups = append(objData.pickups, NewPickup(ammoPickupType, 10, pos))
+			switch obj.ObjectType {
+			case "scenery":
+				s := NewScenery(def.newSprites()[0], pos, def.Effect, def.Sound, def.Drop, def.Physics, def.Collide)
+				s.def = def
+				s.entity.health = def.Health
+				objData.addScenery(s, pl)
+				if err := applyEntityProperties(s.entity, obj); err != nil {
+					return nil, err
+				}
+			case "enemy":
+				e := NewEnemy(EnemyType(obj.Name), def, pos)
+				objData.addEnemy(e, pl)
+				if err := applyEnemyProperties(e, obj); err != nil {
+					return nil, err
+				}
+			case "pickup":
+				amount, err := getIntProperty("amount", obj, def.Amount)
+				if err != nil {
+					return nil, err
+				}
+				p := NewPickup(def, amount, pos)
+				objData.addPickup(p, pl)
+				if err := applyEntityProperties(p.entity, obj); err != nil {
+					return nil, err
+				}
 			}
-			if obj.Name == "health" {
-				objData.pickups = append(objData.pickups, NewPickup(healthPickupType, 3, pos))
-			}
-			if obj.Name == "book" {
-				objData.pickups = append(objData.pickups, NewPickup(bookPickupType, 1, pos))
-			}
-			if obj.Name == "key" {
-				objData.pickups = append(objData.pickups, NewPickup(keyPickupType, 1, pos))
-			}
-			break
 		}
 		//for _, p := range obj.Properties {
 		//	if p.Name == "team" {
@@ -165,14 +330,162 @@ func loadObjectData(grid *tiledgrid.TiledGrid) *objectData {
 		//	}
 		//}
 	}
-	return objData
+	return objData, nil
+}
+
+// GridTileSize is the size in pixels of a map cell in Tiled.
+const GridTileSize = 16
+
+// placement is where and how an object sits in the level, in map units.
+type placement struct {
+	pos    vector
+	facing vector
+	// collision width relative to a grid tile, 0 when the object has no width
+	widthScale float64
+}
+
+// newPlacement uses the centre of the object's rectangle as its position.
+// Tiled rotates objects clockwise around their top left corner, so the centre
+// is rotated with it, and a rotation of 0 faces east.
+func newPlacement(obj *tiledgrid.ObjectData) placement {
+	angle := obj.Rotation * math.Pi / 180
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	halfW, halfH := obj.Width/2, obj.Height/2
+	return placement{
+		pos: vector{
+			x: (obj.X + halfW*cos - halfH*sin) / GridTileSize,
+			y: (obj.Y + halfW*sin + halfH*cos) / GridTileSize,
+		},
+		facing: vector{
+			x: cos,
+			y: sin,
+		},
+		widthScale: obj.Width / GridTileSize,
+	}
+}
+
+func (p placement) apply(e *entity) {
+	e.facing = p.facing
+	if p.widthScale > 0 {
+		e.width = e.width * p.widthScale
+	}
 }
 
-func getStringProperty(name string, obj *tiledgrid.ObjectData) string {
-	for _, p := range obj.Properties {
-		if p.Name == name {
-			return (p.Value).(string)
+func (od *objectData) addScenery(s *scenery, p placement) {
+	p.apply(s.entity)
+	od.scenery = append(od.scenery, s)
+}
+
+func (od *objectData) addEnemy(e *enemy, p placement) {
+	p.apply(e.entity)
+	od.enemies = append(od.enemies, e)
+}
+
+func (od *objectData) addPickup(pk *pickup, p placement) {
+	p.apply(pk.entity)
+	od.pickups = append(od.pickups, pk)
+}
+
+func (od *objectData) addPortal(pt *portal, p placement) {
+	p.apply(pt.entity)
+	od.portals = append(od.portals, pt)
+}
+
+// applyEntityProperties lets the properties on a placed object override the
+// defaults from its definition.
+func applyEntityProperties(e *entity, obj *tiledgrid.ObjectData) error {
+	var err error
+	if e.health, err = getIntProperty("health", obj, e.health); err != nil {
+		return err
+	}
+	if e.speed, err = getFloatProperty("speed", obj, e.speed); err != nil {
+		return err
+	}
+	if e.dropItem, err = getStringProperty("drop", obj, e.dropItem); err != nil {
+		return err
+	}
+	if _, ok := entityDefs.Pickups[e.dropItem]; e.dropItem != "" && e.dropItem != "end" && !ok {
+		return fmt.Errorf("object %q: unknown drop %q", obj.Name, e.dropItem)
+	}
+	dir, err := getStringProperty("dir", obj, "")
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		facing, ok := dirVector(dir)
+		if !ok {
+			return fmt.Errorf("object %q: dir %q is not one of north, south, east or west", obj.Name, dir)
 		}
+		e.facing = facing
+	}
+	return nil
+}
+
+func applyEnemyProperties(e *enemy, obj *tiledgrid.ObjectData) error {
+	if err := applyEntityProperties(e.entity, obj); err != nil {
+		return err
+	}
+	var err error
+	if e.asleep, err = getBoolProperty("asleep", obj, false); err != nil {
+		return err
+	}
+	if e.ambush, err = getBoolProperty("ambush", obj, false); err != nil {
+		return err
+	}
+	return nil
+}
+
+// dirVector returns the unit vector for a compass direction name.
+func dirVector(dir string) (vector, bool) {
+	switch dir {
+	case "north":
+		return vector{x: 0, y: -1}, true
+	case "south":
+		return vector{x: 0, y: 1}, true
+	case "east":
+		return vector{x: 1, y: 0}, true
+	case "west":
+		return vector{x: -1, y: 0}, true
+	}
+	return vector{}, false
+}
+
+func getStringProperty(name string, obj *tiledgrid.ObjectData, def string) (string, error) {
+	v, err := obj.Properties.String(name, def)
+	if err != nil {
+		return def, fmt.Errorf("object %q: %w", obj.Name, err)
+	}
+	return v, nil
+}
+
+func getFileProperty(name string, obj *tiledgrid.ObjectData, def string) (string, error) {
+	v, err := obj.Properties.File(name, def)
+	if err != nil {
+		return def, fmt.Errorf("object %q: %w", obj.Name, err)
+	}
+	return v, nil
+}
+
+func getIntProperty(name string, obj *tiledgrid.ObjectData, def int) (int, error) {
+	v, err := obj.Properties.Int(name, def)
+	if err != nil {
+		return def, fmt.Errorf("object %q: %w", obj.Name, err)
+	}
+	return v, nil
+}
+
+func getFloatProperty(name string, obj *tiledgrid.ObjectData, def float64) (float64, error) {
+	v, err := obj.Properties.Float(name, def)
+	if err != nil {
+		return def, fmt.Errorf("object %q: %w", obj.Name, err)
+	}
+	return v, nil
+}
+
+func getBoolProperty(name string, obj *tiledgrid.ObjectData, def bool) (bool, error) {
+	v, err := obj.Properties.Bool(name, def)
+	if err != nil {
+		return def, fmt.Errorf("object %q: %w", obj.Name, err)
 	}
-	return ""
+	return v, nil
 }
@@ -0,0 +1,219 @@
+package raycast
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// CheckLevel loads the map fileName from maps and reports problems that make
+// the level broken or unfinishable. Textures are looked up in textures the
+// same way the renderer loads them. An error is returned only if the map
+// cannot be loaded at all.
+func CheckLevel(maps fs.FS, fileName string, textures fs.FS) ([]string, error) {
+	l, err := LoadLevelFS(maps, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return append(l.check(textures), l.checkExits(maps, fileName)...), nil
+}
+
+// checkExits makes sure every exit leads to a map that loads and has the
+// spawn the exit names.
+func (l *level) checkExits(maps fs.FS, fileName string) []string {
+	var problems []string
+	for _, p := range l.objectData.portals {
+		if p.exit == nil {
+			continue
+		}
+		dest := path.Join(path.Dir(fileName), p.exit.mapFile)
+		target, err := LoadLevelFS(maps, dest)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("exit to %s: %v", p.exit.mapFile, err))
+			continue
+		}
+		if _, ok := target.objectData.spawns[p.exit.spawn]; p.exit.spawn != "" && !ok {
+			problems = append(problems, fmt.Sprintf("exit to %s: no spawn %q", p.exit.mapFile, p.exit.spawn))
+		}
+	}
+	return problems
+}
+
+func (l *level) check(textures fs.FS) []string {
+	var problems []string
+	od := l.objectData
+
+	if !od.hasStart && len(od.spawns) == 0 {
+		// maps only reached through exits need a spawn instead
+		problems = append(problems, "no start object")
+	}
+	switch od.startDir {
+	case "", "north", "south", "east", "west":
+	default:
+		problems = append(problems, fmt.Sprintf("start dir %q is not one of north, south, east or west", od.startDir))
+	}
+
+	for _, obj := range od.unknown {
+		problems = append(problems, fmt.Sprintf("unknown %s object %q at x=%g y=%g", obj.ObjectType, obj.Name, obj.X, obj.Y))
+	}
+
+	if _, err := fs.Stat(textures, l.settings.sky+".png"); err != nil {
+		problems = append(problems, fmt.Sprintf("sky %q has no %s.png", l.settings.sky, l.settings.sky))
+	}
+	for _, name := range l.textureNames() {
+		if _, err := fs.Stat(textures, name+".png"); err != nil {
+			problems = append(problems, fmt.Sprintf("texture %q has no %s.png", name, name))
+		}
+	}
+
+	lockedDoors := 0
+	for _, column := range l.tiles {
+		for _, t := range column {
+			if t.door && t.locked {
+				lockedDoors++
+			}
+		}
+	}
+	keys := 0
+	for _, p := range od.pickups {
+		if p.pickupType == keyPickupType {
+			keys++
+		}
+	}
+	if lockedDoors > keys {
+		problems = append(problems, fmt.Sprintf("%d locked doors but only %d keys", lockedDoors, keys))
+	}
+
+	if od.hasStart {
+		reachable := l.reachableFrom(od.startPos)
+		for _, p := range od.portals {
+			pos := mapPos{x: int(p.entity.pos.x), y: int(p.entity.pos.y)}
+			if !reachable[pos] {
+				problems = append(problems, fmt.Sprintf("portal at %d,%d cannot be reached from the start", pos.x, pos.y))
+			}
+		}
+	}
+
+	return problems
+}
+
+// textureNames returns every wall, floor, ceiling and door texture the level
+// uses, including animation frames, sorted.
+func (l *level) textureNames() []string {
+	seen := map[string]bool{}
+	for _, column := range l.tiles {
+		for _, t := range column {
+			for _, name := range []string{t.wallTex, t.wallTexN, t.wallTexS, t.wallTexE, t.wallTexW, t.floorTex, t.ceilingTex, t.doorTex} {
+				if name != "" {
+					seen[name] = true
+				}
+			}
+			for _, a := range t.animations {
+				for _, frame := range a.frames {
+					seen[frame] = true
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reachableFrom returns the tiles the player can walk to from pos, picking
+// up the keys they come across on the way. Unlocked doors count as walkable.
+// Keys are used up by the doors they open, so which locked doors get opened
+// matters: every choice of door is tried for each key in hand, and a tile
+// counts as reachable if any order of opening doors leads to it.
+func (l *level) reachableFrom(pos vector) map[mapPos]bool {
+	keysAt := map[mapPos]int{}
+	for _, p := range l.objectData.pickups {
+		if p.pickupType == keyPickupType {
+			keysAt[mapPos{x: int(p.entity.pos.x), y: int(p.entity.pos.y)}]++
+		}
+	}
+	start := mapPos{x: int(pos.x), y: int(pos.y)}
+
+	reachable := map[mapPos]bool{}
+	// states are the sets of doors opened so far, sorted
+	queue := [][]mapPos{nil}
+	seen := map[string]bool{"": true}
+	for len(queue) > 0 {
+		opened := queue[0]
+		queue = queue[1:]
+		area, keys, lockedDoors := l.fill(start, opened, keysAt)
+		for p := range area {
+			reachable[p] = true
+		}
+		if keys <= len(opened) {
+			continue
+		}
+		for _, door := range lockedDoors {
+			next := sortedMapPos(append(append([]mapPos(nil), opened...), door))
+			key := fmt.Sprint(next)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			queue = append(queue, next)
+		}
+	}
+	return reachable
+}
+
+// fill flood fills the tiles walkable from start with the opened doors
+// unlocked. It returns them with the number of keys lying on them and the
+// locked doors at their edge, sorted.
+func (l *level) fill(start mapPos, opened []mapPos, keysAt map[mapPos]int) (map[mapPos]bool, int, []mapPos) {
+	unlocked := map[mapPos]bool{}
+	for _, p := range opened {
+		unlocked[p] = true
+	}
+	area := map[mapPos]bool{}
+	locked := map[mapPos]bool{}
+	keys := 0
+	open := []mapPos{start}
+	for len(open) > 0 {
+		p := open[len(open)-1]
+		open = open[:len(open)-1]
+		if p.x < 0 || p.x >= l.width || p.y < 0 || p.y >= l.height || area[p] {
+			continue
+		}
+		t := l.tiles[p.x][p.y]
+		if t.door && t.locked && !unlocked[p] {
+			locked[p] = true
+			continue
+		}
+		if t.block && !t.door {
+			continue
+		}
+		area[p] = true
+		keys += keysAt[p]
+		open = append(open,
+			mapPos{x: p.x + 1, y: p.y},
+			mapPos{x: p.x - 1, y: p.y},
+			mapPos{x: p.x, y: p.y + 1},
+			mapPos{x: p.x, y: p.y - 1},
+		)
+	}
+	lockedDoors := make([]mapPos, 0, len(locked))
+	for p := range locked {
+		lockedDoors = append(lockedDoors, p)
+	}
+	return area, keys, sortedMapPos(lockedDoors)
+}
+
+// sortedMapPos sorts positions by x then y, in place.
+func sortedMapPos(ps []mapPos) []mapPos {
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].x != ps[j].x {
+			return ps[i].x < ps[j].x
+		}
+		return ps[i].y < ps[j].y
+	})
+	return ps
+}
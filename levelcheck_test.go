@@ -0,0 +1,163 @@
+package raycast
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"raycast.com/tiledgrid"
+)
+
+func TestCheckLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		level string
+		// changes the map before it's loaded, for problems a text level can't
+		// draw
+		edit func(grid *tiledgrid.TiledGrid)
+		want []string
+	}{
+		{
+			name: "fine",
+			level: `
+#####
+#S.E#
+#####`,
+		},
+		{
+			name: "no start",
+			level: `
+#####
+#..E#
+#####`,
+			want: []string{"no start object"},
+		},
+		{
+			name: "bad dir",
+			level: `
+#####
+#^.E#
+#####`,
+			edit: func(grid *tiledgrid.TiledGrid) {
+				grid.Layers[1].Objects[0].Properties[0].Value = "up"
+			},
+			want: []string{`start dir "up" is not one of north, south, east or west`},
+		},
+		{
+			name: "unknown object",
+			level: `
+#####
+#SeE#
+#####`,
+			edit: func(grid *tiledgrid.TiledGrid) {
+				grid.Layers[1].Objects[1].Name = "dragon"
+			},
+			want: []string{`unknown enemy object "dragon" at x=32 y=16`},
+		},
+		{
+			name: "missing texture",
+			level: `
+1: no-such-wall
+
+#####
+#S.E1
+#####`,
+			want: []string{`texture "no-such-wall" has no no-such-wall.png`},
+		},
+		{
+			name: "too few keys",
+			level: `
+#######
+#SkL.L#
+#####E#
+#######`,
+			want: []string{
+				"2 locked doors but only 1 keys",
+				"portal at 5,2 cannot be reached from the start",
+			},
+		},
+		{
+			name: "unreachable portal",
+			level: `
+#######
+#S.#.E#
+#######`,
+			want: []string{"portal at 5,1 cannot be reached from the start"},
+		},
+		{
+			name: "locked portal",
+			level: `
+#######
+#S.L.E#
+#######`,
+			want: []string{
+				"1 locked doors but only 0 keys",
+				"portal at 5,1 cannot be reached from the start",
+			},
+		},
+		{
+			// the one key in reach has to go on the door to the portal, not
+			// the dead end behind the door that comes first in map order
+			name: "key choice",
+			level: `
+#######
+#.#####
+#L#####
+#S.k..#
+#####L#
+####kE#
+#######`,
+		},
+		{
+			name: "key behind a door",
+			level: `
+##########
+#SkL.kL.E#
+##########`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid, err := parseTextLevel([]byte(tt.level))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit != nil {
+				tt.edit(grid)
+			}
+			l, err := newLevel(grid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := l.check(os.DirFS("res")); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got problems %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCheckLevelFiles checks levels loaded from files, following their
+// exits.
+func TestCheckLevelFiles(t *testing.T) {
+	problems, err := CheckLevel(os.DirFS("res/maps"), "cellar.txt", os.DirFS("res"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("cellar.txt has problems: %q", problems)
+	}
+
+	fsys := fstest.MapFS{"broken.txt": {Data: []byte("#####\n#..E#\n#####\n")}}
+	problems, err = CheckLevel(fsys, "broken.txt", os.DirFS("res"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"no start object"}; !reflect.DeepEqual(problems, want) {
+		t.Errorf("got problems %q, want %q", problems, want)
+	}
+
+	if _, err := CheckLevel(fsys, "missing.txt", os.DirFS("res")); err == nil {
+		t.Error("no error checking a map that doesn't exist")
+	}
+}
@@ -0,0 +1,15 @@
+package raycast
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if err := LoadEntityDefinitions(os.DirFS("res"), "entities.json"); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
@@ -15,41 +15,17 @@ const (
 )
 
 type pickup struct {
+	def        *entityDef
 	entity     *entity
 	pickupType pickupType
 	amount     int
 }
 
-func NewPickup(t pickupType, amount int, pos vector) *pickup {
-	var s *sprite
-	switch t {
-	case ammoPickupType:
-		s = NewSprite("ammo")
-		break
-	case healthPickupType:
-		s = NewSprite("health")
-		break
-	case keyPickupType:
-		s = NewAnimatedSprite("key", &animation{
-			numFrames: 4,
-			numTime:   0.2 * 1000,
-			isLoop:    true,
-		})
-		break
-	case soulPickupType:
-		s = NewAnimatedSprite("soul", &animation{
-			numFrames: 4,
-			numTime:   0.2 * 1000,
-			isLoop:    true,
-		})
-		break
-	case bookPickupType:
-		s = NewSprite("book")
-		break
-	}
+func NewPickup(def *entityDef, amount int, pos vector) *pickup {
 	p := &pickup{
-		entity:     NewEntity(pos, s),
-		pickupType: t,
+		def:        def,
+		entity:     NewEntity(pos, def.newSprites()...),
+		pickupType: def.Pickup,
 		amount:     amount,
 	}
 	return p
@@ -13,11 +13,15 @@ const maxAmmo = 30
 const maxHealth = 10
 const screenFlashTime = 200 // millis
 
+// defaultPlaneLength is half the camera plane width for DefaultFOV.
+const defaultPlaneLength = 0.5
+
 type player struct {
 	pos              vector
 	dir              vector
 	strafeDir        vector
 	plane            vector
+	planeLength      float64
 	oldMousePos      int
 	ammo             int
 	fireRateTimer    float64
@@ -48,6 +52,7 @@ func NewPlayer(pos vector, dir string) *player {
 			x: 0,
 			y: 1,
 		},
+		planeLength: defaultPlaneLength,
 		pos:         pos,
 		fireRateMax: 400.0, // millis
 		ammo:        30,
@@ -119,6 +124,33 @@ func NewPlayer(pos vector, dir string) *player {
 	return p
 }
 
+// face turns the player to look along dir, keeping the camera plane and
+// strafe direction perpendicular to it.
+func (r *player) face(dir vector) {
+	dir = normalizeVector(dir)
+	r.dir = dir
+	r.strafeDir = vector{
+		x: -dir.y,
+		y: dir.x,
+	}
+	r.plane = scaleVector(r.strafeDir, r.planeLength)
+}
+
+// setFOV widens or narrows the camera plane to show fov degrees across.
+func (r *player) setFOV(fov float64) {
+	r.planeLength = fovPlaneLength(fov)
+	r.plane = scaleVector(normalizeVector(r.strafeDir), r.planeLength)
+}
+
+// carry takes over what the player had on them in another map.
+func (r *player) carry(from *player) {
+	r.ammo = from.ammo
+	r.health = from.health
+	r.oldHealth = from.oldHealth
+	r.keys = from.keys
+	r.souls = from.souls
+}
+
 func (r *player) Update(w *World, delta float64) error {
 	if r.oldHealth > r.health {
 		w.soundPlayer.PlaySound("player-hurt")
@@ -156,9 +188,9 @@ func (r *player) Update(w *World, delta float64) error {
 			x: r.pos.x + (r.dir.x * checkDistance),
 			y: r.pos.y + (r.dir.y * checkDistance),
 		}
-		t := w.getTileAtPoint(checkPos)
-		if t.door {
-			tryToOpenDoor(w, r, t)
+		pos := mapPos{x: int(checkPos.x), y: int(checkPos.y)}
+		if t := w.getTile(pos.x, pos.y); t != nil && t.door {
+			tryToOpenDoor(w, r, pos)
 		}
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
@@ -248,23 +280,22 @@ func (r *player) TakeDamage(amount int) {
 	r.screenFlashColor = hurtScreenFlashColor
 }
 
-func tryToOpenDoor(w *World, r *player, t *tile) {
-	if t.block {
+// tryToOpenDoor opens a shut or closing door and closes an open one. A
+// locked door takes a key the first time it opens and stays unlocked.
+func tryToOpenDoor(w *World, r *player, pos mapPos) {
+	t := w.tiles[pos.x][pos.y]
+	switch t.doorState {
+	case doorClosed, doorClosing:
 		if t.locked {
 			if r.keys <= 0 {
 				w.soundPlayer.PlaySound("thud")
 				return
 			}
 			r.keys -= 1
-			//w.soundPlayer.PlaySound("door") // play click sound
-		}
-		t.block = false
-		w.soundPlayer.PlaySound("door")
-	} else {
-		playerT := w.getTileAtPoint(r.pos)
-		if playerT != t {
-			t.block = true
-			w.soundPlayer.PlaySound("door")
+			t.locked = false
 		}
+		w.openDoor(pos)
+	default:
+		w.closeDoor(pos)
 	}
 }
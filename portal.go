@@ -1,12 +1,24 @@
 package raycast
 
 import (
-	"fmt"
 	"math"
 )
 
 type portal struct {
 	entity *entity
+	// where the portal leads, or nil if it ends the level
+	exit *levelExit
+	// whether the player was on the portal last update
+	touching bool
+}
+
+// levelExit names the map an exit leads to, relative to the map holding the
+// exit, and the spawn object to put the player on there. With no spawn the
+// player keeps their position in the world, for maps stitched together in a
+// Tiled world file, or goes to the map's start.
+type levelExit struct {
+	mapFile string
+	spawn   string
 }
 
 func NewPortal(pos vector) *portal {
@@ -21,13 +33,28 @@ func NewPortal(pos vector) *portal {
 	return p
 }
 
+// NewExit builds a portal that takes the player to another map.
+func NewExit(pos vector, exit *levelExit) *portal {
+	p := NewPortal(pos)
+	p.exit = exit
+	return p
+}
+
 func (r *portal) Update(w *World, delta float64) {
 	r.entity.Update(delta, w)
-	withinX := math.Abs(w.player.pos.x-r.entity.pos.x) < ((w.player.width + r.entity.width) / 2)
-	withinY := math.Abs(w.player.pos.y-r.entity.pos.y) < ((w.player.width + r.entity.width) / 2)
-	if withinX && withinY {
-		fmt.Printf("entity player collided with portal\n ")
-		fmt.Printf("level won!!")
-		panic("player won")
+	touching := r.touches(w.player)
+	if touching && !r.touching {
+		if r.exit != nil {
+			w.exit = r.exit
+		} else {
+			w.complete = true
+		}
 	}
+	r.touching = touching
+}
+
+func (r *portal) touches(p *player) bool {
+	withinX := math.Abs(p.pos.x-r.entity.pos.x) < ((p.width + r.entity.width) / 2)
+	withinY := math.Abs(p.pos.y-r.entity.pos.y) < ((p.width + r.entity.width) / 2)
+	return withinX && withinY
 }
@@ -9,7 +9,9 @@ type ray struct {
 	distance float64
 	wallX    float64
 	dir      vector
-	texture  string
+	// texture hit, at the frame it's on if it animates
+	texture string
+	flip    textureFlip
 }
 
 func calculateRay(w *World, cameraX float64) ray {
@@ -65,50 +67,57 @@ func calculateRay(w *World, cameraX float64) ray {
 	var texture string
 	var side = 0
 	var t *tile
+	// set when the ray stops on a door leaf rather than a tile face
+	doorHit := false
+	var doorDist, doorX float64
 
 	for !tileFound && distance < maxDistance {
 
 		t = w.getTile(rayMapPos.x, rayMapPos.y)
 		if t != nil {
-			texture = t.wallTex
-			if t.block {
-				tileFound = true
-				if t.door {
-					texture = t.doorTex
-					if t.north {
-						if rayLength.y > rayLength.x && rayUnitStepSize.y < 0.5 {
-							side = 0
-							texture = t.doorTex
-						} else {
-							rayLength.y = rayLength.y - (rayUnitStepSize.y / 2)
-
-						}
-					} else {
-						if rayLength.x > rayLength.y && rayUnitStepSize.x < 0.5 {
+			texture = t.wallTexture(side, rayDir)
+			if t.door {
+				// the door leaf runs across the middle of the tile and
+				// slides sideways as it opens, so only the part that hasn't
+				// slid away yet stops the ray
+				if t.north {
+					mid := (float64(rayMapPos.y) + 0.5 - rayStart.y) / rayDir.y
+					if mid >= distance && mid < rayLength.x && mid < rayLength.y {
+						x := rayStart.x + mid*rayDir.x - float64(rayMapPos.x)
+						if x >= t.doorOpen {
+							doorHit, doorDist, doorX = true, mid, x-t.doorOpen
 							side = 1
-							texture = t.doorTex
-						} else {
-							rayLength.x = rayLength.x - (rayUnitStepSize.x / 2)
 						}
 					}
-				}
-			}
-			if t.door {
-				if t.north {
-					if rayLength.y > rayLength.x {
-						tileFound = true
+					if !doorHit && rayLength.x < rayLength.y {
+						// the ray leaves through the side of the doorway
 						side = 0
-						texture = t.wallTex
+						texture = t.wallTexture(side, rayDir)
+						tileFound = true
 					}
 				} else {
-					if rayLength.x > rayLength.y {
-						tileFound = true
+					mid := (float64(rayMapPos.x) + 0.5 - rayStart.x) / rayDir.x
+					if mid >= distance && mid < rayLength.x && mid < rayLength.y {
+						y := rayStart.y + mid*rayDir.y - float64(rayMapPos.y)
+						if y >= t.doorOpen {
+							doorHit, doorDist, doorX = true, mid, y-t.doorOpen
+							side = 0
+						}
+					}
+					if !doorHit && rayLength.y < rayLength.x {
 						side = 1
-						texture = t.wallTex
+						texture = t.wallTexture(side, rayDir)
+						tileFound = true
 					}
 				}
+				if doorHit {
+					texture = t.doorTex
+					tileFound = true
+				}
+			} else if t.block {
+				tileFound = true
 			}
-			t.seen = true
+			t.markSeen()
 		}
 		if !tileFound {
 			if rayLength.x < rayLength.y {
@@ -127,7 +136,9 @@ func calculateRay(w *World, cameraX float64) ray {
 
 	perpWallDist := 256.0
 	if tileFound {
-		if t != nil && t.door {
+		if doorHit {
+			perpWallDist = doorDist
+		} else if t != nil && t.door {
 			if side == 0 {
 				perpWallDist = rayLength.x
 			} else {
@@ -143,19 +154,29 @@ func calculateRay(w *World, cameraX float64) ray {
 	}
 
 	var wallX float64
-	if side == 0 {
-		wallX = rayStart.y + (perpWallDist * rayDir.y)
+	if doorHit {
+		wallX = doorX
 	} else {
-		wallX = rayStart.x + (perpWallDist * rayDir.x)
+		if side == 0 {
+			wallX = rayStart.y + (perpWallDist * rayDir.y)
+		} else {
+			wallX = rayStart.x + (perpWallDist * rayDir.x)
+		}
+		wallX -= math.Floor(wallX)
+	}
+
+	var flip textureFlip
+	if t != nil {
+		flip = t.wallFlip
 	}
-	wallX -= math.Floor(wallX)
 
 	return ray{
 		distance: perpWallDist,
 		side:     side,
 		wallX:    wallX,
 		dir:      rayDir,
-		texture:  texture,
+		texture:  w.texture(t, texture),
+		flip:     flip,
 	}
 }
 
@@ -1,6 +1,8 @@
 package raycast
 
 type scenery struct {
+	// definition the scenery was placed from, if any
+	def        *entityDef
 	entity     *entity
 	effect     effectType
 	sound      string
@@ -13,6 +13,7 @@ import (
 type SoundPlayer struct {
 	audioContext *audio.Context
 	players      map[string]*audio.Player
+	music        *audio.Player
 }
 
 const sampleRate = 44100
@@ -53,6 +54,11 @@ func (r *SoundPlayer) LoadSound(name string) {
 	r.players[name] = p
 }
 
+func (r *SoundPlayer) HasSound(name string) bool {
+	_, ok := r.players[name]
+	return ok
+}
+
 func (r SoundPlayer) PlaySound(name string) {
 	p, ok := r.players[name]
 	if !ok {
@@ -62,3 +68,28 @@ func (r SoundPlayer) PlaySound(name string) {
 	p.Rewind() // we need to rewind the tape
 	p.Play()
 }
+
+// PlayMusic loops a track from res/music, replacing any track already playing.
+func (r *SoundPlayer) PlayMusic(name string) {
+	if r.music != nil {
+		r.music.Close()
+		r.music = nil
+	}
+	b, err := os.ReadFile("res/music/" + name + ".mp3")
+	if err != nil {
+		fmt.Println("failed to open music: ", name, err)
+		return
+	}
+	s, err := mp3.DecodeWithSampleRate(sampleRate, bytes.NewReader(b))
+	if err != nil {
+		fmt.Println("failed to decode music: ", name, err)
+		return
+	}
+	p, err := r.audioContext.NewPlayer(audio.NewInfiniteLoop(s, s.Length()))
+	if err != nil {
+		fmt.Println("failed to play music: ", name, err)
+		return
+	}
+	r.music = p
+	r.music.Play()
+}
@@ -1,25 +1,31 @@
 package raycast
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
 	"image"
+	"image/draw"
 )
 
 var (
-	textImage           = LoadEbitenImage("text-source.png")
-	textCharacterImages = map[rune]*ebiten.Image{}
+	// loaded on first use so tools that only load levels need no resources
+	textImage image.Image
+	// textImage blown up by each scale text has been drawn at
+	scaledTextImages = map[int]image.Image{}
+	// where each character is in textImage
+	textCharacterRects = map[rune]image.Rectangle{}
 )
 
-func RenderText(image *ebiten.Image, str string, x int, y int) {
-	renderText(image, str, x+1, y+1, true)
-	renderText(image, str, x, y, false)
+// RenderText draws str with a drop shadow, with its top left corner at x, y,
+// and each pixel of the font drawn scale pixels square.
+func RenderText(img draw.Image, str string, x int, y int, scale int) {
+	renderText(img, str, x+scale, y+scale, scale, true)
+	renderText(img, str, x, y, scale, false)
 }
 
-func renderText(img *ebiten.Image, str string, ox, oy int, shadow bool) {
-	op := &ebiten.DrawImageOptions{}
-	if shadow {
-		op.ColorM.ChangeHSV(1, 1, 0)
+func renderText(img draw.Image, str string, ox, oy int, scale int, shadow bool) {
+	if textImage == nil {
+		textImage = LoadImage("text-source.png")
 	}
+	src := scaledTextImage(scale)
 	x := 0
 	y := 0
 	const (
@@ -32,7 +38,7 @@ func renderText(img *ebiten.Image, str string, ox, oy int, shadow bool) {
 			y += ch
 			continue
 		}
-		s, ok := textCharacterImages[c]
+		rect, ok := textCharacterRects[c]
 		if !ok {
 			cval := int(c)
 			index := -1
@@ -59,17 +65,40 @@ func renderText(img *ebiten.Image, str string, ox, oy int, shadow bool) {
 			}
 			if index != -1 {
 				sx := index * cw
-				rect := image.Rect(sx, 0, sx+cw-1, ch-1)
-				s = textImage.SubImage(rect).(*ebiten.Image)
-				textCharacterImages[c] = s
+				rect = image.Rect(sx, 0, sx+cw-1, ch-1)
+				textCharacterRects[c] = rect
 			}
 		}
-		if s != nil {
-			op.GeoM.Reset()
-			op.GeoM.Translate(float64(ox), float64(oy))
-			op.GeoM.Translate(float64(x), float64(y))
-			img.DrawImage(s, op)
+		if !rect.Empty() {
+			srcRect := image.Rectangle{Min: rect.Min.Mul(scale), Max: rect.Max.Mul(scale)}
+			dst := srcRect.Sub(srcRect.Min).Add(image.Pt(ox+x*scale, oy+y*scale))
+			if shadow {
+				// the character's shape in black
+				draw.DrawMask(img, dst, image.Black, image.Point{}, src, srcRect.Min, draw.Over)
+			} else {
+				draw.Draw(img, dst, src, srcRect.Min, draw.Over)
+			}
 			x += cw - 4
 		}
 	}
 }
+
+// scaledTextImage returns textImage with each pixel drawn scale pixels
+// square.
+func scaledTextImage(scale int) image.Image {
+	if scale <= 1 {
+		return textImage
+	}
+	if img, ok := scaledTextImages[scale]; ok {
+		return img
+	}
+	b := textImage.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			img.Set(x, y, textImage.At(b.Min.X+x/scale, b.Min.Y+y/scale))
+		}
+	}
+	scaledTextImages[scale] = img
+	return img
+}
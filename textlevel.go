@@ -0,0 +1,267 @@
+package raycast
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"raycast.com/tiledgrid"
+)
+
+// TextLevelExt is the file extension of plain text levels.
+const TextLevelExt = ".txt"
+
+// legend entries a text level may change, with their defaults
+var textLevelDefaults = map[string]string{
+	"wallTex":           "wall-1",
+	"floorTex":          "floor",
+	"ceilingTex":        "ceiling",
+	"doorTex":           "door",
+	"doorWallTex":       "door-wall",
+	"doorFloorTex":      "door-floor",
+	"lockedDoorTex":     "door-locked",
+	"lockedDoorWallTex": "door-wall-locked",
+	"enemy":             "blob",
+}
+
+type textObject struct {
+	objectType string
+	name       string
+	dir        string
+}
+
+var textLevelObjects = map[rune]textObject{
+	'S': {objectType: "level", name: "start"},
+	'^': {objectType: "level", name: "start", dir: "north"},
+	'v': {objectType: "level", name: "start", dir: "south"},
+	'<': {objectType: "level", name: "start", dir: "west"},
+	'>': {objectType: "level", name: "start", dir: "east"},
+	'E': {objectType: "level", name: "end"},
+	'e': {objectType: "enemy"},
+	'b': {objectType: "scenery", name: "barrel"},
+	'k': {objectType: "pickup", name: "key"},
+	'a': {objectType: "pickup", name: "ammo"},
+	'h': {objectType: "pickup", name: "health"},
+}
+
+// LoadTextLevel loads a level from a plain text map in fsys. A text level is
+// a legend header followed by the map drawn one character per tile:
+//
+//	wallTex: wall-1
+//	floorTex: floor-rock
+//	1: wall-bookshelf
+//	name: The Cellar
+//
+//	#######
+//	#S..e.#
+//	#.b#1.D..E
+//	#######
+//
+// Header lines are "key: value". The texture keys below set the textures used
+// for the map characters, a single character key adds a wall character with
+// the given texture, enemy picks the enemy placed by 'e', and any other key
+// becomes a map property such as name, sky or fogDistance.
+//
+// Map characters are '#' wall, '.' or ' ' floor, 'D' door, 'L' locked door,
+// 'S' start facing east, '^' 'v' '<' '>' start facing north, south, west or
+// east, 'E' end portal, 'e' enemy, 'b' barrel, 'k' key, 'a' ammo and 'h'
+// health. Doors run across the passage they sit in. Rows shorter than the
+// longest are filled out with wall, and a level has at most one start.
+func LoadTextLevel(fsys fs.FS, fileName string) (*level, error) {
+	data, err := fs.ReadFile(fsys, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("opening map file: %w", err)
+	}
+	grid, err := parseTextLevel(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing map file %s: %w", fileName, err)
+	}
+	return newLevel(grid)
+}
+
+// parseTextLevel builds the same map Tiled would have saved for the text
+// level, so it loads exactly like any other.
+func parseTextLevel(data []byte) (*tiledgrid.TiledGrid, error) {
+	legend := map[string]string{}
+	for k, v := range textLevelDefaults {
+		legend[k] = v
+	}
+	walls := map[rune]string{}
+	grid := &tiledgrid.TiledGrid{
+		TileWidth:  GridTileSize,
+		TileHeight: GridTileSize,
+	}
+
+	var rows [][]rune
+	firstRow := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if rows == nil {
+			if line == "" {
+				continue
+			}
+			if i := strings.Index(line, ":"); i >= 0 {
+				key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+				if value == "" {
+					return nil, fmt.Errorf("line %d: no value for %q", lineNum, key)
+				}
+				switch {
+				case len([]rune(key)) == 1:
+					r := []rune(key)[0]
+					if _, taken := textLevelObjects[r]; taken || strings.ContainsRune("#. DL", r) {
+						return nil, fmt.Errorf("line %d: %q is already a map character", lineNum, key)
+					}
+					walls[r] = value
+				case textLevelDefaults[key] != "":
+					legend[key] = value
+				default:
+					grid.Properties = append(grid.Properties, textLevelProperty(key, value))
+				}
+				continue
+			}
+			firstRow = lineNum
+		}
+		rows = append(rows, []rune(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no map after the header")
+	}
+
+	for _, row := range rows {
+		if len(row) > grid.Width {
+			grid.Width = len(row)
+		}
+	}
+	grid.Height = len(rows)
+	for y, row := range rows {
+		// left open, the tiles past the end of a short row would be a hole
+		// in the wall
+		for len(row) < grid.Width {
+			row = append(row, '#')
+		}
+		rows[y] = row
+	}
+
+	ex := &tiledExport{ids: map[exportTileType]int{}}
+	tileLayer := &tiledgrid.Layer{
+		Name:   "Tile Layer 1",
+		Type:   "tilelayer",
+		Width:  grid.Width,
+		Height: grid.Height,
+		Data:   make([]int, grid.Width*grid.Height),
+	}
+	objectLayer := &tiledgrid.Layer{
+		Name: "Object Layer 1",
+		Type: "objectgroup",
+	}
+	at := func(x, y int) rune {
+		if y < 0 || y >= len(rows) || x < 0 || x >= len(rows[y]) {
+			return 0
+		}
+		return rows[y][x]
+	}
+	isWall := func(r rune) bool {
+		_, ok := walls[r]
+		return r == '#' || ok
+	}
+	floor := tiledgrid.TileData{FloorTex: legend["floorTex"], CeilingTex: legend["ceilingTex"]}
+	// line and column of the start, if one has been drawn
+	var startLine, startColumn int
+
+	for y, row := range rows {
+		for x, r := range row {
+			td := floor
+			switch {
+			case r == '#':
+				td = tiledgrid.TileData{Block: true, WallTex: legend["wallTex"]}
+			case r == 'D' || r == 'L':
+				td = tiledgrid.TileData{
+					Block:      true,
+					Door:       true,
+					North:      isWall(at(x-1, y)) && isWall(at(x+1, y)),
+					DoorTex:    legend["doorTex"],
+					WallTex:    legend["doorWallTex"],
+					FloorTex:   legend["doorFloorTex"],
+					CeilingTex: legend["doorFloorTex"],
+				}
+				if r == 'L' {
+					td.Locked = true
+					td.DoorTex = legend["lockedDoorTex"]
+					td.WallTex = legend["lockedDoorWallTex"]
+				}
+			case r == '.' || r == ' ':
+			case isWall(r):
+				td = tiledgrid.TileData{Block: true, WallTex: walls[r]}
+			default:
+				obj, ok := textLevelObjects[r]
+				if !ok {
+					return nil, fmt.Errorf("line %d, column %d: unknown map character %q", firstRow+y, x+1, r)
+				}
+				if obj.objectType == "enemy" {
+					obj.name = legend["enemy"]
+				}
+				if obj.name == "start" {
+					if startLine != 0 {
+						return nil, fmt.Errorf("line %d, column %d: second start, the first is at line %d, column %d", firstRow+y, x+1, startLine, startColumn)
+					}
+					startLine, startColumn = firstRow+y, x+1
+				}
+				objectLayer.Objects = append(objectLayer.Objects, newTextObject(len(objectLayer.Objects)+1, obj, x, y))
+			}
+			tileLayer.Data[y*grid.Width+x] = ex.id(td, nil) + 1
+		}
+	}
+
+	grid.Layers = []*tiledgrid.Layer{tileLayer, objectLayer}
+	grid.TileSetReferences = []*tiledgrid.TileSetReference{{FirstGid: 1}}
+	grid.TileSet = []*tiledgrid.TileSet{{
+		TileWidth:  GridTileSize,
+		TileHeight: GridTileSize,
+		FirstGid:   1,
+		Tiles:      ex.tiles,
+	}}
+	return grid, nil
+}
+
+// newTextObject places an object on the middle of the tile at x, y.
+func newTextObject(id int, obj textObject, x int, y int) tiledgrid.TiledObject {
+	o := tiledgrid.TiledObject{
+		Id:     id,
+		Name:   obj.name,
+		Type:   obj.objectType,
+		X:      float64(x * GridTileSize),
+		Y:      float64(y * GridTileSize),
+		Width:  GridTileSize,
+		Height: GridTileSize,
+	}
+	if obj.dir != "" {
+		o.Properties = tiledgrid.Properties{{Name: "dir", Type: "string", Value: obj.dir}}
+	}
+	return o
+}
+
+// textLevelProperty turns a header line into a map property. Numbers become
+// floats, and names and file names always stay strings.
+func textLevelProperty(key string, value string) *tiledgrid.TileConfigProp {
+	switch key {
+	case "name", "sky", "music":
+		return &tiledgrid.TileConfigProp{Name: key, Type: "string", Value: value}
+	}
+	if strings.HasPrefix(value, "#") {
+		return &tiledgrid.TileConfigProp{Name: key, Type: "color", Value: value}
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return &tiledgrid.TileConfigProp{Name: key, Type: "float", Value: f}
+	}
+	return &tiledgrid.TileConfigProp{Name: key, Type: "string", Value: value}
+}
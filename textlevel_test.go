@@ -0,0 +1,194 @@
+package raycast
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTextLevelLegend(t *testing.T) {
+	l, err := loadTestTextLevel(t, `
+wallTex: rock-wall
+floorTex: floor-flagstone
+ceilingTex: ceiling-stone
+doorTex: door-bars
+doorWallTex: wall-door
+doorFloorTex: floor-door
+lockedDoorTex: door-bars-locked
+lockedDoorWallTex: wall-door-locked
+enemy: alien
+1: wall-bookshelf
+name: The Test
+sky: stars
+fogColor: #ff101820
+fogDistance: 12
+
+#######
+#S.1e.#
+###D###
+#..L..#
+#######`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		x, y int
+		want tile
+	}{
+		{x: 0, y: 0, want: tile{block: true, wallTex: "rock-wall"}},
+		{x: 1, y: 1, want: tile{floorTex: "floor-flagstone", ceilingTex: "ceiling-stone"}},
+		{x: 3, y: 1, want: tile{block: true, wallTex: "wall-bookshelf"}},
+		{x: 3, y: 2, want: tile{block: true, door: true, north: true, wallTex: "wall-door", doorTex: "door-bars",
+			floorTex: "floor-door", ceilingTex: "floor-door"}},
+		// floor on either side, so it runs north to south
+		{x: 3, y: 3, want: tile{block: true, door: true, locked: true, wallTex: "wall-door-locked", doorTex: "door-bars-locked",
+			floorTex: "floor-door", ceilingTex: "floor-door"}},
+	}
+	for _, tt := range tests {
+		if got := exportedTile(l.tiles[tt.x][tt.y]); got != exportedTile(&tt.want) {
+			t.Errorf("tile %d,%d: got %+v, want %+v", tt.x, tt.y, got, exportedTile(&tt.want))
+		}
+	}
+
+	s := l.settings
+	if s.name != "The Test" || s.sky != "stars" || s.fogDistance != 12 || s.fogColor != (color.RGBA{R: 0x10, G: 0x18, B: 0x20, A: 0xff}) {
+		t.Errorf("got settings %+v", *s)
+	}
+	if len(l.objectData.enemies) != 1 || l.objectData.enemies[0].enemyType != "alien" {
+		t.Errorf("got enemies %v, want one alien", l.objectData.enemies)
+	}
+}
+
+func TestTextLevelObjects(t *testing.T) {
+	tests := []struct {
+		char       rune
+		objectType string
+		name       string
+	}{
+		{'E', "level", "end"},
+		{'e', "enemy", "blob"},
+		{'b', "scenery", "barrel"},
+		{'k', "pickup", "key"},
+		{'a', "pickup", "ammo"},
+		{'h', "pickup", "health"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.char), func(t *testing.T) {
+			grid, err := parseTextLevel([]byte("#####\n#S." + string(tt.char) + "#\n#####"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects := grid.Layers[1].Objects
+			if len(objects) != 2 {
+				t.Fatalf("got %d objects, want the start and one more", len(objects))
+			}
+			o := objects[1]
+			if o.Type != tt.objectType || o.Name != tt.name || o.X != 3*GridTileSize || o.Y != GridTileSize {
+				t.Errorf("got %s %q at %g,%g, want %s %q at 48,16", o.Type, o.Name, o.X, o.Y, tt.objectType, tt.name)
+			}
+			if _, err := newLevel(grid); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTextLevelStart(t *testing.T) {
+	tests := []struct {
+		char rune
+		dir  vector
+	}{
+		{'S', vector{x: 1, y: 0}},
+		{'^', vector{x: 0, y: -1}},
+		{'v', vector{x: 0, y: 1}},
+		{'<', vector{x: -1, y: 0}},
+		{'>', vector{x: 1, y: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.char), func(t *testing.T) {
+			fsys := fstest.MapFS{"start.txt": {Data: []byte("#####\n#.." + string(tt.char) + "#\n#####\n")}}
+			w, err := NewWorldFS(fsys, "start.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (vector{x: 3.5, y: 1.5}); w.player.pos != want {
+				t.Errorf("got start at %v, want %v", w.player.pos, want)
+			}
+			if w.player.dir != tt.dir {
+				t.Errorf("got start facing %v, want %v", w.player.dir, tt.dir)
+			}
+		})
+	}
+}
+
+// TestTextLevelShortRows checks the tiles past the end of a short row are
+// wall rather than left open.
+func TestTextLevelShortRows(t *testing.T) {
+	l, err := loadTestTextLevel(t, `
+#######
+#S....#
+#..
+#######`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 3; x < 7; x++ {
+		if got := l.tiles[x][2]; !got.block || got.wallTex != "wall-1" {
+			t.Errorf("tile %d,2 is %+v, want a wall", x, exportedTile(got))
+		}
+	}
+}
+
+func TestTextLevelErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		level string
+		want  string
+	}{
+		{
+			name:  "unknown character",
+			level: "#####\n#S.?#\n#####",
+			want:  `line 2, column 4: unknown map character '?'`,
+		},
+		{
+			name:  "wall character without a texture",
+			level: "1:\n\n#####\n#S.1#\n#####",
+			want:  `line 1: no value for "1"`,
+		},
+		{
+			name:  "legend texture without a value",
+			level: "name: Test\nwallTex:\n\n#####\n#S..#\n#####",
+			want:  `line 2: no value for "wallTex"`,
+		},
+		{
+			name:  "map character in the legend",
+			level: "k: wall-2\n\n#####\n#S..#\n#####",
+			want:  `line 1: "k" is already a map character`,
+		},
+		{
+			name:  "two starts",
+			level: "\n#####\n#S.^#\n#####",
+			want:  `line 3, column 4: second start, the first is at line 3, column 2`,
+		},
+		{
+			name:  "no map",
+			level: "name: Test\n",
+			want:  "no map after the header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestTextLevel(t, tt.level)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func loadTestTextLevel(t *testing.T, text string) (*level, error) {
+	t.Helper()
+	return LoadTextLevel(fstest.MapFS{"level.txt": {Data: []byte(text)}}, "level.txt")
+}
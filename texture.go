@@ -0,0 +1,40 @@
+package raycast
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// texture is an image decoded once into premultiplied RGBA bytes, so
+// drawing reads texels straight from memory rather than through
+// image.Image and a colour model conversion for every pixel.
+type texture struct {
+	width  int
+	height int
+	pix    []byte
+}
+
+func newTexture(img image.Image) *texture {
+	b := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) || rgba.Stride != 4*b.Dx() {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	}
+	return &texture{
+		width:  b.Dx(),
+		height: b.Dy(),
+		pix:    rgba.Pix,
+	}
+}
+
+// at returns the texel at x, y, or transparent black outside the texture.
+func (t *texture) at(x, y int) color.RGBA {
+	if x < 0 || y < 0 || x >= t.width || y >= t.height {
+		return color.RGBA{}
+	}
+	i := (y*t.width + x) * 4
+	p := t.pix[i : i+4 : i+4]
+	return color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+}
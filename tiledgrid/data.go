@@ -0,0 +1,108 @@
+package tiledgrid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UnmarshalJSON accepts layer data either as a plain array of gids or as a
+// base64 string, optionally zlib or gzip compressed.
+func (l *Layer) UnmarshalJSON(b []byte) error {
+	type rawLayer Layer
+	aux := struct {
+		*rawLayer
+		Data   json.RawMessage `json:"data"`
+		Layers []*Layer        `json:"layers"`
+	}{
+		rawLayer: (*rawLayer)(l),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return l.unmarshalError(err)
+	}
+	l.layers = aux.Layers
+	if len(aux.Data) == 0 {
+		return nil
+	}
+	if l.Encoding != "base64" {
+		if err := json.Unmarshal(aux.Data, &l.Data); err != nil {
+			return l.unmarshalError(err)
+		}
+		return l.checkSize()
+	}
+	var text string
+	if err := json.Unmarshal(aux.Data, &text); err != nil {
+		return l.unmarshalError(err)
+	}
+	data, err := decodeBase64Data(text, l.Compression)
+	if err != nil {
+		return fmt.Errorf("layer %q: %w", l.Name, err)
+	}
+	l.Data = data
+	return l.checkSize()
+}
+
+// checkSize reports a tile layer whose data doesn't have a gid for every
+// tile, which would shift every row after the first missing one.
+func (l *Layer) checkSize() error {
+	if len(l.Data) != l.Width*l.Height {
+		return fmt.Errorf("layer %q: %d tiles of data for a %dx%d layer", l.Name, len(l.Data), l.Width, l.Height)
+	}
+	return nil
+}
+
+// unmarshalError names the layer instead of wrapping err, whose offset
+// counts from the start of the layer rather than the file and so can't be
+// turned into a line and column.
+func (l *Layer) unmarshalError(err error) error {
+	return fmt.Errorf("layer %q: %v", l.Name, err)
+}
+
+// decodeBase64Data decodes a base64 string of little-endian uint32 gids.
+func decodeBase64Data(text string, compression string) ([]int, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 data: %w", err)
+	}
+
+	var r io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing zlib data: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing gzip data: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	default:
+		return nil, fmt.Errorf("unsupported data compression %q", compression)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing %s data: %w", compression, err)
+	}
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("data length %d is not a multiple of 4", len(b))
+	}
+
+	data := make([]int, len(b)/4)
+	for i := range data {
+		data[i] = int(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return data, nil
+}
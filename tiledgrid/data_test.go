@@ -0,0 +1,145 @@
+package tiledgrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayerData(t *testing.T) {
+	gids := []int{1, 2, 0, 0, 1, 2 | flippedVerticallyFlag}
+	tests := []struct {
+		name        string
+		encoding    string
+		compression string
+		data        string
+	}{
+		{name: "array", data: "[1, 2, 0, 0, 1, 1073741826]"},
+		{name: "base64", encoding: "base64", data: `"` + encodeTestData(t, gids, "") + `"`},
+		{name: "zlib", encoding: "base64", compression: "zlib", data: `"` + encodeTestData(t, gids, "zlib") + `"`},
+		{name: "gzip", encoding: "base64", compression: "gzip", data: `"` + encodeTestData(t, gids, "gzip") + `"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l Layer
+			if err := json.Unmarshal([]byte(testLayerJSON(tt.encoding, tt.compression, tt.data)), &l); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(l.Data, gids) {
+				t.Errorf("got data %v, want %v", l.Data, gids)
+			}
+		})
+	}
+}
+
+func TestLayerDataErrors(t *testing.T) {
+	zlibData := encodeTestData(t, []int{1, 2, 0, 0, 1, 2}, "zlib")
+	tests := []struct {
+		name        string
+		encoding    string
+		compression string
+		data        string
+		want        string
+	}{
+		{
+			name: "short array",
+			data: "[1, 2, 0, 0, 1]",
+			want: `layer "walls": 5 tiles of data for a 3x2 layer`,
+		},
+		{
+			name:     "long base64",
+			encoding: "base64",
+			data:     `"` + encodeTestData(t, []int{1, 2, 0, 0, 1, 2, 1}, "") + `"`,
+			want:     `layer "walls": 7 tiles of data for a 3x2 layer`,
+		},
+		{
+			name:     "partial gid",
+			encoding: "base64",
+			data:     `"AQAAAAI="`,
+			want:     `layer "walls": data length 5 is not a multiple of 4`,
+		},
+		{
+			name:     "bad base64",
+			encoding: "base64",
+			data:     `"not base64!"`,
+			want:     `layer "walls": decoding base64 data`,
+		},
+		{
+			name:        "truncated zlib",
+			encoding:    "base64",
+			compression: "zlib",
+			data:        `"` + truncateBase64(t, zlibData) + `"`,
+			want:        `layer "walls": decompressing zlib data`,
+		},
+		{
+			name:        "truncated gzip",
+			encoding:    "base64",
+			compression: "gzip",
+			data:        `"` + truncateBase64(t, encodeTestData(t, []int{1, 2, 0, 0, 1, 2}, "gzip")) + `"`,
+			want:        `layer "walls": decompressing gzip data`,
+		},
+		{
+			name:        "zstd",
+			encoding:    "base64",
+			compression: "zstd",
+			data:        `"` + zlibData + `"`,
+			want:        `layer "walls": unsupported data compression "zstd"`,
+		},
+		{
+			name: "data of the wrong type",
+			data: `{"gid": 1}`,
+			want: `layer "walls": json: cannot unmarshal object into Go value of type []int`,
+		},
+		{
+			name:     "base64 that isn't a string",
+			encoding: "base64",
+			data:     "[1, 2]",
+			want:     `layer "walls": json: cannot unmarshal array into Go value of type string`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l Layer
+			err := json.Unmarshal([]byte(testLayerJSON(tt.encoding, tt.compression, tt.data)), &l)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestLayerErrorPosition checks that a bad layer in a map file is reported by
+// name and not with a line and column counted from the start of the layer.
+func TestLayerErrorPosition(t *testing.T) {
+	data := `{
+  "width": 3,
+  "height": 2,
+  "layers": [
+    ` + testLayerJSON("", "", `[1, "two", 0, 0, 1, 2]`) + `
+  ]
+}`
+	var tg TiledGrid
+	err := decodeJSON([]byte(data), &tg)
+	if err == nil {
+		t.Fatal("no error decoding a layer with a string gid")
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, `layer "walls": `) {
+		t.Errorf("got error %q, want it to start with the layer name", msg)
+	}
+}
+
+func testLayerJSON(encoding string, compression string, data string) string {
+	return fmt.Sprintf(`{"name": "walls", "type": "tilelayer", "width": 3, "height": 2, "encoding": %q, "compression": %q, "data": %s}`,
+		encoding, compression, data)
+}
+
+// truncateBase64 cuts the end off base64 encoded data.
+func truncateBase64(t *testing.T, data string) string {
+	t.Helper()
+	if len(data) < 16 {
+		t.Fatalf("%q is too short to truncate", data)
+	}
+	return data[:len(data)-12]
+}
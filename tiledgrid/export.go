@@ -0,0 +1,312 @@
+package tiledgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultTileSize = 16
+	exportVersion   = 1.5
+	exportTiled     = "1.5.0"
+)
+
+type jsonMap struct {
+	CompressionLevel int                 `json:"compressionlevel"`
+	Width            int                 `json:"width"`
+	Height           int                 `json:"height"`
+	Infinite         bool                `json:"infinite"`
+	Layers           []interface{}       `json:"layers"`
+	NextLayerId      int                 `json:"nextlayerid"`
+	NextObjectId     int                 `json:"nextobjectid"`
+	Orientation      string              `json:"orientation"`
+	Properties       Properties          `json:"properties,omitempty"`
+	RenderOrder      string              `json:"renderorder"`
+	TiledVersion     string              `json:"tiledversion"`
+	TileWidth        int                 `json:"tilewidth"`
+	TileHeight       int                 `json:"tileheight"`
+	TileSets         []*TileSetReference `json:"tilesets"`
+	Type             string              `json:"type"`
+	Version          float64             `json:"version"`
+}
+
+type jsonTileLayer struct {
+	Data    []int  `json:"data"`
+	Height  int    `json:"height"`
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Opacity int    `json:"opacity"`
+	Type    string `json:"type"`
+	Visible bool   `json:"visible"`
+	Width   int    `json:"width"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+}
+
+type jsonObjectLayer struct {
+	DrawOrder string       `json:"draworder"`
+	Id        int          `json:"id"`
+	Name      string       `json:"name"`
+	Objects   []jsonObject `json:"objects"`
+	Opacity   int          `json:"opacity"`
+	Type      string       `json:"type"`
+	Visible   bool         `json:"visible"`
+	X         int          `json:"x"`
+	Y         int          `json:"y"`
+}
+
+type jsonObject struct {
+	Height     float64    `json:"height"`
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Properties Properties `json:"properties,omitempty"`
+	Rotation   float64    `json:"rotation"`
+	Type       string     `json:"type"`
+	Visible    bool       `json:"visible"`
+	Width      float64    `json:"width"`
+	X          float64    `json:"x"`
+	Y          float64    `json:"y"`
+}
+
+type jsonTileSet struct {
+	Columns      int           `json:"columns"`
+	Image        string        `json:"image"`
+	ImageHeight  int           `json:"imageheight"`
+	ImageWidth   int           `json:"imagewidth"`
+	Margin       int           `json:"margin"`
+	Name         string        `json:"name"`
+	Spacing      int           `json:"spacing"`
+	TileCount    int           `json:"tilecount"`
+	TiledVersion string        `json:"tiledversion"`
+	TileHeight   int           `json:"tileheight"`
+	Tiles        []*TileConfig `json:"tiles"`
+	TileWidth    int           `json:"tilewidth"`
+	Type         string        `json:"type"`
+	Version      float64       `json:"version"`
+}
+
+// WriteJSON writes the map in Tiled's JSON map format, with tile layer data
+// as plain arrays of gids.
+func (tg *TiledGrid) WriteJSON(w io.Writer) error {
+	m := jsonMap{
+		CompressionLevel: -1,
+		Width:            tg.Width,
+		Height:           tg.Height,
+		Layers:           []interface{}{},
+		Orientation:      "orthogonal",
+		Properties:       tg.Properties,
+		RenderOrder:      "right-down",
+		TiledVersion:     exportTiled,
+		TileWidth:        orDefault(tg.TileWidth, defaultTileSize),
+		TileHeight:       orDefault(tg.TileHeight, defaultTileSize),
+		TileSets:         tg.TileSetReferences,
+		Type:             "map",
+		Version:          exportVersion,
+	}
+
+	maxObjectId := 0
+	for i, l := range tg.Layers {
+		id := i + 1
+		if l.isTileLayer() {
+			m.Layers = append(m.Layers, jsonTileLayer{
+				Data:    l.Data,
+				Height:  l.Height,
+				Id:      id,
+				Name:    l.Name,
+				Opacity: 1,
+				Type:    "tilelayer",
+				Visible: true,
+				Width:   l.Width,
+			})
+			continue
+		}
+		ol := jsonObjectLayer{
+			DrawOrder: "topdown",
+			Id:        id,
+			Name:      l.Name,
+			Objects:   []jsonObject{},
+			Opacity:   1,
+			Type:      "objectgroup",
+			Visible:   true,
+		}
+		for _, o := range l.Objects {
+			if o.Id > maxObjectId {
+				maxObjectId = o.Id
+			}
+			ol.Objects = append(ol.Objects, jsonObject{
+				Height:     o.Height,
+				Id:         o.Id,
+				Name:       o.Name,
+				Properties: o.Properties,
+				Rotation:   o.Rotation,
+				Type:       o.Type,
+				Visible:    true,
+				Width:      o.Width,
+				X:          o.X,
+				Y:          o.Y,
+			})
+		}
+		m.Layers = append(m.Layers, ol)
+	}
+	m.NextLayerId = len(tg.Layers) + 1
+	m.NextObjectId = maxObjectId + 1
+
+	return writeIndentedJSON(w, m)
+}
+
+// WriteJSON writes the tileset in Tiled's JSON tileset format.
+func (ts *TileSet) WriteJSON(w io.Writer) error {
+	tiles := ts.Tiles
+	if tiles == nil {
+		tiles = []*TileConfig{}
+	}
+	return writeIndentedJSON(w, jsonTileSet{
+		Columns:      ts.Columns,
+		Image:        ts.ImageFileName,
+		ImageHeight:  ts.ImageHeight,
+		ImageWidth:   ts.ImageWidth,
+		Name:         ts.Name,
+		TileCount:    ts.TileCount,
+		TiledVersion: exportTiled,
+		TileHeight:   orDefault(ts.TileHeight, defaultTileSize),
+		Tiles:        tiles,
+		TileWidth:    orDefault(ts.TileWidth, defaultTileSize),
+		Type:         "tileset",
+		Version:      exportVersion,
+	})
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(v)
+}
+
+func orDefault(v int, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// PlaceholderImage draws each tile in the tileset as a flat square, coloured
+// from its properties so the same kind of tile always looks the same in
+// Tiled.
+func (ts *TileSet) PlaceholderImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ts.ImageWidth, ts.ImageHeight))
+	tileWidth := orDefault(ts.TileWidth, defaultTileSize)
+	tileHeight := orDefault(ts.TileHeight, defaultTileSize)
+	for _, tile := range ts.Tiles {
+		names := []string{}
+		for _, p := range tile.Properties {
+			names = append(names, fmt.Sprintf("%s=%v", p.Name, p.Value))
+		}
+		sort.Strings(names)
+		h := fnv.New32a()
+		h.Write([]byte(strings.Join(names, ",")))
+		sum := h.Sum32()
+		c := color.RGBA{R: uint8(sum), G: uint8(sum >> 8), B: uint8(sum >> 16), A: 255}
+
+		tx := (tile.Id % ts.Columns) * tileWidth
+		ty := (tile.Id / ts.Columns) * tileHeight
+		for y := ty; y < ty+tileHeight; y++ {
+			for x := tx; x < tx+tileWidth; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// NewTileSet lays tiles out in rows of columns square tiles of tileSize
+// pixels, as the tileset saved as source. Its image is named after source.
+func NewTileSet(source string, tiles []*TileConfig, tileSize int, columns int) *TileSet {
+	rows := (len(tiles) + columns - 1) / columns
+	if rows == 0 {
+		rows = 1
+	}
+	name := strings.TrimSuffix(source, path.Ext(source))
+	return &TileSet{
+		Name:          path.Base(name),
+		TileWidth:     tileSize,
+		TileHeight:    tileSize,
+		Columns:       columns,
+		TileCount:     columns * rows,
+		ImageFileName: name + ".png",
+		ImageWidth:    columns * tileSize,
+		ImageHeight:   rows * tileSize,
+		Tiles:         tiles,
+	}
+}
+
+// Properties returns the tile properties that load back as td, leaving out
+// those at their zero value, sorted by name. Position, flip flags and
+// animation are not tile properties and are ignored.
+func (td TileData) Properties() Properties {
+	props := Properties{}
+	addBool := func(name string, v bool) {
+		if v {
+			props = append(props, &TileConfigProp{Name: name, Type: "bool", Value: true})
+		}
+	}
+	addString := func(name string, v string) {
+		if v != "" {
+			props = append(props, &TileConfigProp{Name: name, Type: "string", Value: v})
+		}
+	}
+	addBool("block", td.Block)
+	addString("ceilingTex", td.CeilingTex)
+	addBool("door", td.Door)
+	addString("doorTex", td.DoorTex)
+	addString("floorTex", td.FloorTex)
+	addBool("locked", td.Locked)
+	addBool("north", td.North)
+	addString("wallTex", td.WallTex)
+	addString("wallTexE", td.WallTexE)
+	addString("wallTexN", td.WallTexN)
+	addString("wallTexS", td.WallTexS)
+	addString("wallTexW", td.WallTexW)
+	return props
+}
+
+// WriteMap writes the map to mapFile, with its tileset next to it under the
+// source the map refers to it by and a placeholder tileset image.
+func WriteMap(mapFile string, tg *TiledGrid, ts *TileSet) error {
+	if len(tg.TileSetReferences) == 0 {
+		return errors.New("writing map: no tileset reference")
+	}
+	dir := filepath.Dir(mapFile)
+	if err := WriteFile(mapFile, tg.WriteJSON); err != nil {
+		return err
+	}
+	if err := WriteFile(filepath.Join(dir, tg.TileSetReferences[0].Source), ts.WriteJSON); err != nil {
+		return err
+	}
+	return WriteFile(filepath.Join(dir, ts.ImageFileName), func(w io.Writer) error {
+		return png.Encode(w, ts.PlaceholderImage())
+	})
+}
+
+// WriteFile creates the file name and fills it with write.
+func WriteFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return f.Close()
+}
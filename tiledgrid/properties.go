@@ -0,0 +1,203 @@
+package tiledgrid
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Properties is a list of Tiled custom properties with typed accessors. Each
+// accessor returns the default when the property is missing and an error
+// when it is set to a value of the wrong type.
+type Properties []*TileConfigProp
+
+// Get returns the property with the given name, or nil.
+func (p Properties) Get(name string) *TileConfigProp {
+	for _, prop := range p {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// Has reports whether the property is set.
+func (p Properties) Has(name string) bool {
+	prop := p.Get(name)
+	return prop != nil && prop.Value != nil
+}
+
+// Bool returns a bool property, or def if unset and an error if it isn't a bool.
+func (p Properties) Bool(name string, def bool) (bool, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(bool)
+	if !ok {
+		return def, prop.typeError("bool")
+	}
+	return v, nil
+}
+
+// Int returns an int property, or def if unset and an error if it isn't a whole number.
+func (p Properties) Int(name string, def int) (int, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(float64)
+	if !ok || v != math.Trunc(v) {
+		return def, prop.typeError("int")
+	}
+	return int(v), nil
+}
+
+// Float returns a number property, or def if unset and an error if it isn't a number.
+func (p Properties) Float(name string, def float64) (float64, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(float64)
+	if !ok {
+		return def, prop.typeError("float")
+	}
+	return v, nil
+}
+
+// String returns a string property, or def if unset and an error if it isn't a string.
+func (p Properties) String(name string, def string) (string, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(string)
+	if !ok {
+		return def, prop.typeError("string")
+	}
+	return v, nil
+}
+
+// File returns a file property, which Tiled stores as a path relative to the
+// map.
+func (p Properties) File(name string, def string) (string, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(string)
+	if !ok {
+		return def, prop.typeError("file")
+	}
+	return v, nil
+}
+
+// Color returns a color property. Tiled writes colors as #AARRGGBB, or
+// #RRGGBB for opaque colors; an empty value means unset.
+func (p Properties) Color(name string, def color.RGBA) (color.RGBA, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(string)
+	if !ok {
+		return def, prop.typeError("color")
+	}
+	if v == "" {
+		return def, nil
+	}
+	c, err := parseColor(v)
+	if err != nil {
+		return def, fmt.Errorf("property %q: %w", prop.Name, err)
+	}
+	return c, nil
+}
+
+// Object returns the id of the object an object property refers to, or 0 when
+// it refers to nothing.
+func (p Properties) Object(name string, def int) (int, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return def, nil
+	}
+	v, ok := prop.Value.(float64)
+	if !ok || v != math.Trunc(v) {
+		return def, prop.typeError("object")
+	}
+	return int(v), nil
+}
+
+// Class returns the members of a custom class property. Missing members have
+// no entry, so the accessors on the result fall back to their defaults.
+func (p Properties) Class(name string) (Properties, error) {
+	prop := p.Get(name)
+	if prop == nil || prop.Value == nil {
+		return Properties{}, nil
+	}
+	members, ok := prop.Value.(map[string]interface{})
+	if !ok {
+		return Properties{}, prop.typeError("class")
+	}
+	return classProperties(members), nil
+}
+
+// classProperties turns the members of a class value into properties. The
+// JSON export does not record member types, so they are taken from the
+// decoded values.
+func classProperties(members map[string]interface{}) Properties {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := Properties{}
+	for _, name := range names {
+		props = append(props, &TileConfigProp{
+			Name:  name,
+			Type:  valueType(members[name]),
+			Value: members[name],
+		})
+	}
+	return props
+}
+
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case float64:
+		return "float"
+	case map[string]interface{}:
+		return "class"
+	}
+	return "string"
+}
+
+func (prop *TileConfigProp) typeError(want string) error {
+	return fmt.Errorf("property %q: expected %s, got %s %v", prop.Name, want, prop.Type, prop.Value)
+}
+
+func parseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	if len(hex) == 6 {
+		v |= 0xff000000
+	}
+	return color.RGBA{
+		A: uint8(v >> 24),
+		R: uint8(v >> 16),
+		G: uint8(v >> 8),
+		B: uint8(v),
+	}, nil
+}
@@ -0,0 +1,196 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.9" tiledversion="1.9.2" name="colors" tilewidth="16" tileheight="16" tilecount="100" columns="10">
+ <image source="tileset.png" width="160" height="160"/>
+ <tile id="0">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="ceilingTex" value="door-floor"/>
+   <property name="door" type="bool" value="true"/>
+   <property name="doorTex" value="door"/>
+   <property name="floorTex" value="door-floor"/>
+   <property name="north" type="bool" value="true"/>
+   <property name="wallTex" value="door-wall"/>
+  </properties>
+ </tile>
+ <tile id="1">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="ceilingTex" value="door-floor"/>
+   <property name="door" type="bool" value="true"/>
+   <property name="doorTex" value="door-locked"/>
+   <property name="floorTex" value="door-floor"/>
+   <property name="locked" type="bool" value="true"/>
+   <property name="north" type="bool" value="true"/>
+   <property name="wallTex" value="door-wall-locked"/>
+  </properties>
+ </tile>
+ <tile id="6">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="fancy-rock-wall"/>
+  </properties>
+ </tile>
+ <tile id="7">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="fancy-rock-wall-flag"/>
+  </properties>
+ </tile>
+ <tile id="8">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="fancy-rock-wall-broken"/>
+  </properties>
+ </tile>
+ <tile id="10">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="ceilingTex" value="door-floor"/>
+   <property name="door" type="bool" value="true"/>
+   <property name="doorTex" value="door"/>
+   <property name="floorTex" value="door-floor"/>
+   <property name="wallTex" value="door-wall"/>
+  </properties>
+ </tile>
+ <tile id="11">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="ceilingTex" value="door-floor"/>
+   <property name="door" type="bool" value="true"/>
+   <property name="doorTex" value="door-locked"/>
+   <property name="floorTex" value="door-floor"/>
+   <property name="locked" type="bool" value="true"/>
+   <property name="wallTex" value="door-wall-locked"/>
+  </properties>
+ </tile>
+ <tile id="22">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-cold"/>
+  </properties>
+ </tile>
+ <tile id="23">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="door-wall"/>
+  </properties>
+ </tile>
+ <tile id="24">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="door-floor"/>
+  </properties>
+ </tile>
+ <tile id="30">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-1"/>
+  </properties>
+ </tile>
+ <tile id="31">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-2"/>
+  </properties>
+ </tile>
+ <tile id="32">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-3"/>
+  </properties>
+ </tile>
+ <tile id="33">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-4"/>
+  </properties>
+ </tile>
+ <tile id="34">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-5"/>
+  </properties>
+ </tile>
+ <tile id="35">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-6"/>
+  </properties>
+ </tile>
+ <tile id="36">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-7"/>
+  </properties>
+ </tile>
+ <tile id="37">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-8"/>
+  </properties>
+ </tile>
+ <tile id="38">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-9"/>
+  </properties>
+ </tile>
+ <tile id="39">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="wall-10"/>
+  </properties>
+ </tile>
+ <tile id="40">
+  <properties>
+   <property name="ceilingTex" value="ceiling-olive"/>
+   <property name="floorTex" value="floor-carpet"/>
+  </properties>
+ </tile>
+ <tile id="41">
+  <properties>
+   <property name="floorTex" value="floor-rock"/>
+  </properties>
+ </tile>
+ <tile id="42">
+  <properties>
+   <property name="ceilingTex" value="ceiling-stone"/>
+   <property name="floorTex" value="floor-flagstone"/>
+  </properties>
+ </tile>
+ <tile id="43">
+  <properties>
+   <property name="floorTex" value="floor"/>
+  </properties>
+ </tile>
+ <tile id="44">
+  <properties>
+   <property name="ceilingTex" value="ceiling"/>
+   <property name="floorTex" value="floor"/>
+  </properties>
+ </tile>
+ <tile id="45">
+  <properties>
+   <property name="ceilingTex" value="ceiling-olive"/>
+   <property name="floorTex" value="floor-tiles"/>
+  </properties>
+ </tile>
+ <tile id="46">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="rock-wall"/>
+  </properties>
+ </tile>
+ <tile id="47">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="rock-plinth"/>
+  </properties>
+ </tile>
+ <tile id="48">
+  <properties>
+   <property name="block" type="bool" value="true"/>
+   <property name="wallTex" value="rock-pillar"/>
+  </properties>
+ </tile>
+</tileset>
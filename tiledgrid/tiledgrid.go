@@ -1,12 +1,14 @@
 package tiledgrid
 
 import (
+	"bytes"
 	"encoding/json"
-	"github.com/hajimehoshi/ebiten/v2"
-	_ "image/png"
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
+	"strings"
 )
 
 const (
@@ -14,24 +16,40 @@ const (
 )
 
 type TiledGrid struct {
+	Width             int                 `json:"width"`
+	Height            int                 `json:"height"`
+	TileWidth         int                 `json:"tilewidth"`
+	TileHeight        int                 `json:"tileheight"`
 	Layers            []*Layer            `json:"layers"`
 	TileSetReferences []*TileSetReference `json:"tilesets"`
+	Properties        Properties          `json:"properties"`
 	TileSet           []*TileSet
+	tileTypes         map[int]*TileData
 }
 
 type Layer struct {
-	Data    []int         `json:"Data"`
-	Height  int           `json:"height"`
-	Width   int           `json:"width"`
-	Objects []TiledObject `json:"objects"`
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Data        []int         `json:"data"`
+	Encoding    string        `json:"encoding"`
+	Compression string        `json:"compression"`
+	Height      int           `json:"height"`
+	Width       int           `json:"width"`
+	Objects     []TiledObject `json:"objects"`
+	// layers in a group layer, which LoadTileGrid moves up into the map
+	layers []*Layer
 }
 
 type TiledObject struct {
-	Name       string            `json:"Name"`
-	Type       string            `json:"type"`
-	X          int               `json:"x"`
-	Y          int               `json:"y"`
-	Properties []*TileConfigProp `json:"properties"`
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	X          float64    `json:"x"`
+	Y          float64    `json:"y"`
+	Width      float64    `json:"width"`
+	Height     float64    `json:"height"`
+	Rotation   float64    `json:"rotation"`
+	Properties Properties `json:"properties"`
 }
 
 type TileSetReference struct {
@@ -40,6 +58,11 @@ type TileSetReference struct {
 }
 
 type TileSet struct {
+	Name          string `json:"name"`
+	TileWidth     int    `json:"tilewidth"`
+	TileHeight    int    `json:"tileheight"`
+	Columns       int    `json:"columns"`
+	TileCount     int    `json:"tilecount"`
 	ImageFileName string `json:"image"`
 	ImageWidth    int    `json:"imagewidth"`
 	ImageHeight   int    `json:"imageheight"`
@@ -47,79 +70,150 @@ type TileSet struct {
 	numTilesY     int
 	FirstGid      int
 	Tiles         []*TileConfig `json:"tiles"`
-	image         *ebiten.Image
+	source        string
 }
 
 type TileConfig struct {
-	Id         int               `json:"id"`
-	Properties []*TileConfigProp `json:"properties"`
+	Id         int         `json:"id"`
+	Properties Properties  `json:"properties"`
+	Animation  []TileFrame `json:"animation,omitempty"`
+}
+
+// TileFrame is one frame of a tile animation: another tile in the same
+// tileset, shown for Duration milliseconds.
+type TileFrame struct {
+	TileId   int `json:"tileid" xml:"tileid,attr"`
+	Duration int `json:"duration" xml:"duration,attr"`
 }
 
 type TileConfigProp struct {
-	Name  string      `json:"Name"`
-	Type  string      `json:"type"`
-	Value interface{} `json:"value"`
+	Name         string      `json:"name"`
+	Type         string      `json:"type"`
+	PropertyType string      `json:"propertytype,omitempty"`
+	Value        interface{} `json:"value"`
+}
+
+// NewTileGrid loads a map from the resource directory.
+func NewTileGrid(fileName string) (*TiledGrid, error) {
+	return LoadTileGrid(os.DirFS(resourceDirectory), fileName)
 }
 
-func NewTileGrid(fileName string) *TiledGrid {
+// LoadTileGrid loads the map at name from fsys. Tileset sources are resolved
+// relative to the directory of the map file.
+func LoadTileGrid(fsys fs.FS, name string) (*TiledGrid, error) {
 	var tiledGrid TiledGrid
 
-	configFile, err := os.Open(filepath.Join(resourceDirectory, fileName))
+	data, err := fs.ReadFile(fsys, name)
 	if err != nil {
-		log.Fatal("opening config file", err.Error())
+		return nil, fmt.Errorf("opening map file: %w", err)
 	}
-
-	jsonParser := json.NewDecoder(configFile)
-	if err = jsonParser.Decode(&tiledGrid); err != nil {
-		log.Fatal("parsing config file", err.Error())
+	if isXML(data) {
+		err = decodeTMX(data, &tiledGrid)
+	} else {
+		err = decodeJSON(data, &tiledGrid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing map file %s: %w", name, err)
 	}
+	tiledGrid.Layers = flattenLayers(tiledGrid.Layers)
 
 	tiledGrid.TileSet = []*TileSet{}
 	for _, ref := range tiledGrid.TileSetReferences {
-		tiledGrid.TileSet = append(tiledGrid.TileSet, loadTileSet(ref))
+		ts, err := loadTileSet(fsys, path.Dir(name), ref)
+		if err != nil {
+			return nil, fmt.Errorf("loading tileset for map %s: %w", name, err)
+		}
+		tiledGrid.TileSet = append(tiledGrid.TileSet, ts)
+	}
+	if tiledGrid.tileTypes, err = tiledGrid.buildTileTypes(); err != nil {
+		return nil, fmt.Errorf("loading tiles for map %s: %w", name, err)
 	}
 
-	return &tiledGrid
+	return &tiledGrid, nil
+}
+
+// flattenLayers replaces group layers with the layers in them, in order.
+// Groups only organise a map in the editor, so a layer is read the same
+// whether or not it's in one.
+func flattenLayers(layers []*Layer) []*Layer {
+	var flat []*Layer
+	for _, l := range layers {
+		if l.Type == "group" {
+			flat = append(flat, flattenLayers(l.layers)...)
+			continue
+		}
+		flat = append(flat, l)
+	}
+	return flat
 }
 
-func loadTileSet(ref *TileSetReference) *TileSet {
-	tileSetConfigFile, err := os.Open(filepath.Join(resourceDirectory, ref.Source))
+func loadTileSet(fsys fs.FS, dir string, ref *TileSetReference) (*TileSet, error) {
+	name := path.Join(dir, ref.Source)
+	data, err := fs.ReadFile(fsys, name)
 	if err != nil {
-		log.Fatal("opening config file", err.Error())
+		return nil, fmt.Errorf("opening tileset file: %w", err)
 	}
 
 	var tileSet TileSet
-	jsonParser := json.NewDecoder(tileSetConfigFile)
-	if err = jsonParser.Decode(&tileSet); err != nil {
-		log.Fatal("parsing config file", err.Error())
+	if isXML(data) {
+		err = decodeTSX(data, &tileSet)
+	} else {
+		err = decodeJSON(data, &tileSet)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing tileset file %s: %w", name, err)
 	}
 
 	tileSet.FirstGid = ref.FirstGid
-	return &tileSet
+	tileSet.source = name
+	return &tileSet, nil
 }
 
-func (tg *TiledGrid) getTileSetForIndex(index int) *TileSet {
-	for i, tileSet := range tg.TileSet {
-		if i == len(tg.TileSet)-1 || tg.TileSet[i+1].FirstGid > index {
-			return tileSet
-		}
+// decodeJSON unmarshals data into v, adding the line and column of the
+// offending input to syntax and type errors.
+func decodeJSON(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
 	}
-	// should never happen
-	return nil
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	line, column := position(data, offset)
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
 }
 
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// ObjectData is an object placed in Tiled. X and Y are the pixel position of
+// its top left corner, and Rotation is in degrees clockwise around that
+// corner.
 type ObjectData struct {
+	Id         int
 	Name       string
 	ObjectType string
-	X          int
-	Y          int
-	Properties []*ObjectProperty
-}
-
-type ObjectProperty struct {
-	Name    string
-	ObjType string
-	Value   interface{}
+	X          float64
+	Y          float64
+	Width      float64
+	Height     float64
+	Rotation   float64
+	Properties Properties
 }
 
 func (tg *TiledGrid) GetObjectData() []*ObjectData {
@@ -127,18 +221,15 @@ func (tg *TiledGrid) GetObjectData() []*ObjectData {
 	for _, l := range tg.Layers {
 		for _, obj := range l.Objects {
 			od := &ObjectData{
+				Id:         obj.Id,
 				Name:       obj.Name,
 				ObjectType: obj.Type,
 				X:          obj.X,
 				Y:          obj.Y,
-				Properties: []*ObjectProperty{},
-			}
-			for _, p := range obj.Properties {
-				od.Properties = append(od.Properties, &ObjectProperty{
-					Name:    p.Name,
-					ObjType: p.Type,
-					Value:   p.Value,
-				})
+				Width:      obj.Width,
+				Height:     obj.Height,
+				Rotation:   obj.Rotation,
+				Properties: append(Properties{}, obj.Properties...),
 			}
 			ods = append(ods, od)
 		}
@@ -147,73 +238,247 @@ func (tg *TiledGrid) GetObjectData() []*ObjectData {
 }
 
 type TileData struct {
-	X          int
-	Y          int
-	Block      bool
-	Door       bool
-	North      bool
-	WallTex    string
+	X       int
+	Y       int
+	Block   bool
+	Door    bool
+	North   bool
+	WallTex string
+	// textures for single faces of the wall, named for the side of the tile
+	// they face, used instead of WallTex where set
+	WallTexN   string
+	WallTexS   string
+	WallTexE   string
+	WallTexW   string
 	FloorTex   string
 	DoorTex    string
 	CeilingTex string
 	Locked     bool
+	Flip       Flip
+	// frames the tile cycles through, or nil if it doesn't animate
+	Animation *Animation
 }
 
+// Animation is the decoded frames of an animated tile.
+type Animation struct {
+	Frames []AnimationFrame
+}
+
+// AnimationFrame holds the textures of the tile shown for a frame, which
+// may be empty where the frame tile has none, and how many milliseconds it
+// is shown for.
+type AnimationFrame struct {
+	Tile     *TileData
+	Duration int
+}
+
+// Flip is how a tile was flipped or rotated when placed in Tiled. A
+// diagonal flip swaps x and y and is applied before the other two.
+type Flip struct {
+	Horizontal bool
+	Vertical   bool
+	Diagonal   bool
+}
+
+// Tiled stores the flip flags in the high bits of each gid
+const (
+	flippedHorizontallyFlag = 0x80000000
+	flippedVerticallyFlag   = 0x40000000
+	flippedDiagonallyFlag   = 0x20000000
+	rotatedHexagonalFlag    = 0x10000000
+	flipFlags               = flippedHorizontallyFlag | flippedVerticallyFlag | flippedDiagonallyFlag | rotatedHexagonalFlag
+)
+
+// splitGid separates the tile id in a gid from its flip flags.
+func splitGid(gid int) (int, Flip) {
+	flip := Flip{
+		Horizontal: gid&flippedHorizontallyFlag != 0,
+		Vertical:   gid&flippedVerticallyFlag != 0,
+		Diagonal:   gid&flippedDiagonallyFlag != 0,
+	}
+	return gid &^ flipFlags, flip
+}
+
+// JoinGid adds flip flags to a gid, the reverse of how tiles are read.
+func JoinGid(gid int, flip Flip) int {
+	if flip.Horizontal {
+		gid |= flippedHorizontallyFlag
+	}
+	if flip.Vertical {
+		gid |= flippedVerticallyFlag
+	}
+	if flip.Diagonal {
+		gid |= flippedDiagonallyFlag
+	}
+	return gid
+}
+
+// GetTileData returns the tile at x, y on the first tile layer of the map.
 func (tg *TiledGrid) GetTileData(x int, y int) *TileData {
+	for _, l := range tg.Layers {
+		if l.isTileLayer() {
+			return tg.getLayerTileData(l, x, y)
+		}
+	}
+	return &TileData{X: x, Y: y}
+}
+
+// GetLayerTileData returns the tile at x, y on the tile layer with the given
+// name. An empty tile is returned if the map has no such layer.
+func (tg *TiledGrid) GetLayerTileData(layerName string, x int, y int) *TileData {
+	l := tg.TileLayer(layerName)
+	if l == nil {
+		return &TileData{X: x, Y: y}
+	}
+	return tg.getLayerTileData(l, x, y)
+}
+
+// TileLayer returns the tile layer with the given name, or nil. Names are
+// compared case-insensitively.
+func (tg *TiledGrid) TileLayer(name string) *Layer {
+	for _, l := range tg.Layers {
+		if l.isTileLayer() && strings.EqualFold(l.Name, name) {
+			return l
+		}
+	}
+	return nil
+}
+
+func (l *Layer) isTileLayer() bool {
+	return l.Type == "tilelayer" || (l.Type == "" && len(l.Data) > 0)
+}
+
+func (tg *TiledGrid) getLayerTileData(l *Layer, x int, y int) *TileData {
 	td := TileData{
 		X: x,
 		Y: y,
 	}
-	index := (y * tg.Layers[0].Width) + x
+	index := (y * l.Width) + x
 
-	if index < 0 || index >= len(tg.Layers[0].Data) {
+	if index < 0 || index >= len(l.Data) {
 		// no tile here
 		return &td
 	}
 
-	if x < 0 || y < 0 {
+	if x < 0 || y < 0 || x >= l.Width {
 		return &td
 	}
 
-	tileSetIndex := tg.Layers[0].Data[index]
-	// I think this means there's nothing there ???
-	if tileSetIndex == 0 {
-		return &td
+	gid, flip := splitGid(l.Data[index])
+	if tt, ok := tg.TileTypes()[gid]; ok {
+		td = *tt
+		td.X = x
+		td.Y = y
 	}
+	td.Flip = flip
 
-	ts := tg.getTileSetForIndex(tileSetIndex)
+	return &td
+}
 
-	for _, tile := range ts.Tiles {
-		if tile.Id == tileSetIndex-ts.FirstGid {
-			for _, prop := range tile.Properties {
-				if prop.Name == "block" && prop.Value != nil {
-					td.Block = (prop.Value).(bool)
-				}
-				if prop.Name == "door" && prop.Value != nil {
-					td.Door = (prop.Value).(bool)
-				}
-				if prop.Name == "north" && prop.Value != nil {
-					td.North = (prop.Value).(bool)
-				}
-				if prop.Name == "wallTex" && prop.Value != nil {
-					td.WallTex = (prop.Value).(string)
-				}
-				if prop.Name == "floorTex" && prop.Value != nil {
-					td.FloorTex = (prop.Value).(string)
-				}
-				if prop.Name == "ceilingTex" && prop.Value != nil {
-					td.CeilingTex = (prop.Value).(string)
-				}
-				if prop.Name == "doorTex" && prop.Value != nil {
-					td.DoorTex = (prop.Value).(string)
+// TileTypes returns the decoded tile for every gid that has properties in the
+// map's tilesets. The table is built once and shared, so it must not be
+// modified.
+func (tg *TiledGrid) TileTypes() map[int]*TileData {
+	if tg.tileTypes == nil {
+		// tiles with bad properties were already reported by LoadTileGrid
+		tg.tileTypes, _ = tg.buildTileTypes()
+	}
+	return tg.tileTypes
+}
+
+// UsedTileTypes returns the tile types placed on any tile layer of the map,
+// keyed by gid with the flip flags removed.
+func (tg *TiledGrid) UsedTileTypes() map[int]*TileData {
+	types := tg.TileTypes()
+	used := map[int]*TileData{}
+	for _, l := range tg.Layers {
+		if !l.isTileLayer() {
+			continue
+		}
+		for _, raw := range l.Data {
+			gid, _ := splitGid(raw)
+			if tt, ok := types[gid]; ok {
+				used[gid] = tt
+			}
+		}
+	}
+	return used
+}
+
+// buildTileTypes decodes every tile in the map's tilesets. Tiles with bad
+// properties are left out and the first such error is returned.
+func (tg *TiledGrid) buildTileTypes() (map[int]*TileData, error) {
+	types := map[int]*TileData{}
+	var firstErr error
+	for _, ts := range tg.TileSet {
+		for _, tile := range ts.Tiles {
+			td, err := newTileData(tile)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("tileset %s: tile %d: %w", ts.source, tile.Id, err)
 				}
-				if prop.Name == "locked" && prop.Value != nil {
-					td.Locked = (prop.Value).(bool)
+				continue
+			}
+			types[ts.FirstGid+tile.Id] = td
+		}
+		// frames can only be filled in once every tile in the set is decoded
+		for _, tile := range ts.Tiles {
+			td, ok := types[ts.FirstGid+tile.Id]
+			if !ok || len(tile.Animation) == 0 {
+				continue
+			}
+			td.Animation = &Animation{}
+			for _, f := range tile.Animation {
+				frame, ok := types[ts.FirstGid+f.TileId]
+				if !ok {
+					frame = &TileData{}
 				}
+				td.Animation.Frames = append(td.Animation.Frames, AnimationFrame{Tile: frame, Duration: f.Duration})
 			}
-			break
 		}
 	}
+	return types, firstErr
+}
 
-	return &td
+func newTileData(tile *TileConfig) (*TileData, error) {
+	var err error
+	td := TileData{}
+	props := tile.Properties
+	if td.Block, err = props.Bool("block", false); err != nil {
+		return nil, err
+	}
+	if td.Door, err = props.Bool("door", false); err != nil {
+		return nil, err
+	}
+	if td.North, err = props.Bool("north", false); err != nil {
+		return nil, err
+	}
+	if td.Locked, err = props.Bool("locked", false); err != nil {
+		return nil, err
+	}
+	if td.WallTex, err = props.String("wallTex", ""); err != nil {
+		return nil, err
+	}
+	if td.WallTexN, err = props.String("wallTexN", ""); err != nil {
+		return nil, err
+	}
+	if td.WallTexS, err = props.String("wallTexS", ""); err != nil {
+		return nil, err
+	}
+	if td.WallTexE, err = props.String("wallTexE", ""); err != nil {
+		return nil, err
+	}
+	if td.WallTexW, err = props.String("wallTexW", ""); err != nil {
+		return nil, err
+	}
+	if td.FloorTex, err = props.String("floorTex", ""); err != nil {
+		return nil, err
+	}
+	if td.CeilingTex, err = props.String("ceilingTex", ""); err != nil {
+		return nil, err
+	}
+	if td.DoorTex, err = props.String("doorTex", ""); err != nil {
+		return nil, err
+	}
+	return &td, nil
 }
@@ -0,0 +1,371 @@
+package tiledgrid
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isXML reports whether data looks like a TMX or TSX document rather than a
+// JSON export.
+func isXML(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '<'
+}
+
+type tmxMap struct {
+	Width             int
+	Height            int
+	TileWidth         int
+	TileHeight        int
+	Layers            []*Layer
+	TileSetReferences []*TileSetReference
+	Properties        Properties
+}
+
+// UnmarshalXML keeps tile layers, object groups and layer groups in document
+// order, the same order the JSON export lists them in.
+func (m *tmxMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "width":
+			m.Width, err = strconv.Atoi(attr.Value)
+		case "height":
+			m.Height, err = strconv.Atoi(attr.Value)
+		case "tilewidth":
+			m.TileWidth, err = strconv.Atoi(attr.Value)
+		case "tileheight":
+			m.TileHeight, err = strconv.Atoi(attr.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("map %s: %w", attr.Name.Local, err)
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tileset":
+				var ts tmxTileSetRef
+				if err := d.DecodeElement(&ts, &t); err != nil {
+					return err
+				}
+				if ts.Source == "" {
+					return fmt.Errorf("tileset with firstgid %d: embedded tilesets are not supported", ts.FirstGid)
+				}
+				m.TileSetReferences = append(m.TileSetReferences, &TileSetReference{
+					Source:   ts.Source,
+					FirstGid: ts.FirstGid,
+				})
+			case "properties":
+				var props struct {
+					Properties []tmxProperty `xml:"property"`
+				}
+				if err := d.DecodeElement(&props, &t); err != nil {
+					return err
+				}
+				converted, err := convertProperties(props.Properties)
+				if err != nil {
+					return fmt.Errorf("map: %w", err)
+				}
+				m.Properties = converted
+			case "layer", "objectgroup", "group":
+				layer, err := decodeTMXLayer(d, t)
+				if err != nil {
+					return err
+				}
+				m.Layers = append(m.Layers, layer)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeTMXLayer decodes the tile layer, object group or group of layers
+// that start opens.
+func decodeTMXLayer(d *xml.Decoder, start xml.StartElement) (*Layer, error) {
+	switch start.Name.Local {
+	case "layer":
+		var l tmxLayer
+		if err := d.DecodeElement(&l, &start); err != nil {
+			return nil, err
+		}
+		return l.toLayer()
+	case "objectgroup":
+		var og tmxObjectGroup
+		if err := d.DecodeElement(&og, &start); err != nil {
+			return nil, err
+		}
+		return og.toLayer()
+	}
+
+	group := &Layer{Type: "group"}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "name" {
+			group.Name = attr.Value
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "layer", "objectgroup", "group":
+				layer, err := decodeTMXLayer(d, t)
+				if err != nil {
+					return nil, fmt.Errorf("group %q: %w", group.Name, err)
+				}
+				group.layers = append(group.layers, layer)
+			default:
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			return group, nil
+		}
+	}
+}
+
+type tmxTileSetRef struct {
+	FirstGid int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type tmxLayer struct {
+	Name   string  `xml:"name,attr"`
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   tmxData `xml:"data"`
+}
+
+type tmxData struct {
+	Encoding    string    `xml:"encoding,attr"`
+	Compression string    `xml:"compression,attr"`
+	Text        string    `xml:",chardata"`
+	Tiles       []tmxTile `xml:"tile"`
+}
+
+type tmxTile struct {
+	Gid uint32 `xml:"gid,attr"`
+}
+
+func (l *tmxLayer) toLayer() (*Layer, error) {
+	data, err := l.Data.decode()
+	if err != nil {
+		return nil, fmt.Errorf("layer %q: %w", l.Name, err)
+	}
+	layer := &Layer{
+		Name:        l.Name,
+		Type:        "tilelayer",
+		Data:        data,
+		Encoding:    l.Data.Encoding,
+		Compression: l.Data.Compression,
+		Width:       l.Width,
+		Height:      l.Height,
+	}
+	return layer, layer.checkSize()
+}
+
+func (d *tmxData) decode() ([]int, error) {
+	switch d.Encoding {
+	case "":
+		data := make([]int, len(d.Tiles))
+		for i, t := range d.Tiles {
+			data[i] = int(t.Gid)
+		}
+		return data, nil
+	case "csv":
+		var data []int
+		for _, field := range strings.Split(d.Text, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("decoding csv data: %w", err)
+			}
+			data = append(data, int(gid))
+		}
+		return data, nil
+	case "base64":
+		return decodeBase64Data(d.Text, d.Compression)
+	}
+	return nil, fmt.Errorf("unsupported data encoding %q", d.Encoding)
+}
+
+type tmxObjectGroup struct {
+	Name    string      `xml:"name,attr"`
+	Objects []tmxObject `xml:"object"`
+}
+
+type tmxObject struct {
+	Id         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+func (og *tmxObjectGroup) toLayer() (*Layer, error) {
+	layer := &Layer{Name: og.Name, Type: "objectgroup"}
+	for _, o := range og.Objects {
+		props, err := convertProperties(o.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("object %q: %w", o.Name, err)
+		}
+		objType := o.Type
+		if objType == "" {
+			// Tiled 1.9 renamed the object type to class
+			objType = o.Class
+		}
+		layer.Objects = append(layer.Objects, TiledObject{
+			Id:         o.Id,
+			Name:       o.Name,
+			Type:       objType,
+			X:          o.X,
+			Y:          o.Y,
+			Width:      o.Width,
+			Height:     o.Height,
+			Rotation:   o.Rotation,
+			Properties: props,
+		})
+	}
+	return layer, nil
+}
+
+type tmxProperty struct {
+	Name         string        `xml:"name,attr"`
+	Type         string        `xml:"type,attr"`
+	PropertyType string        `xml:"propertytype,attr"`
+	Value        string        `xml:"value,attr"`
+	Text         string        `xml:",chardata"`
+	Members      []tmxProperty `xml:"properties>property"`
+}
+
+// convertProperties turns XML properties into the values the JSON decoder
+// would have produced, so bools are bools, numbers are float64 and classes
+// are maps of member values.
+func convertProperties(props []tmxProperty) (Properties, error) {
+	var result Properties
+	for _, p := range props {
+		raw := p.Value
+		if raw == "" {
+			// multi-line strings are stored as element text
+			raw = p.Text
+		}
+		propType := p.Type
+		if propType == "" {
+			propType = "string"
+		}
+		var value interface{}
+		var err error
+		switch propType {
+		case "bool":
+			value, err = strconv.ParseBool(raw)
+		case "int", "float", "object":
+			value, err = strconv.ParseFloat(raw, 64)
+		case "class":
+			var members Properties
+			members, err = convertProperties(p.Members)
+			m := map[string]interface{}{}
+			for _, member := range members {
+				m[member.Name] = member.Value
+			}
+			value = m
+		default:
+			value = raw
+		}
+		if err != nil {
+			return nil, fmt.Errorf("property %q: %w", p.Name, err)
+		}
+		result = append(result, &TileConfigProp{
+			Name:         p.Name,
+			Type:         propType,
+			PropertyType: p.PropertyType,
+			Value:        value,
+		})
+	}
+	return result, nil
+}
+
+func decodeTMX(data []byte, tg *TiledGrid) error {
+	var m tmxMap
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	tg.Width = m.Width
+	tg.Height = m.Height
+	tg.TileWidth = m.TileWidth
+	tg.TileHeight = m.TileHeight
+	tg.Layers = m.Layers
+	tg.TileSetReferences = m.TileSetReferences
+	tg.Properties = m.Properties
+	return nil
+}
+
+type tsxTileSet struct {
+	Name       string `xml:"name,attr"`
+	TileWidth  int    `xml:"tilewidth,attr"`
+	TileHeight int    `xml:"tileheight,attr"`
+	Columns    int    `xml:"columns,attr"`
+	TileCount  int    `xml:"tilecount,attr"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+		Width  int    `xml:"width,attr"`
+		Height int    `xml:"height,attr"`
+	} `xml:"image"`
+	Tiles []struct {
+		Id         int           `xml:"id,attr"`
+		Properties []tmxProperty `xml:"properties>property"`
+		Animation  []TileFrame   `xml:"animation>frame"`
+	} `xml:"tile"`
+}
+
+func decodeTSX(data []byte, ts *TileSet) error {
+	var tsx tsxTileSet
+	if err := xml.Unmarshal(data, &tsx); err != nil {
+		return err
+	}
+	ts.Name = tsx.Name
+	ts.TileWidth = tsx.TileWidth
+	ts.TileHeight = tsx.TileHeight
+	ts.Columns = tsx.Columns
+	ts.TileCount = tsx.TileCount
+	ts.ImageFileName = tsx.Image.Source
+	ts.ImageWidth = tsx.Image.Width
+	ts.ImageHeight = tsx.Image.Height
+	for _, t := range tsx.Tiles {
+		props, err := convertProperties(t.Properties)
+		if err != nil {
+			return fmt.Errorf("tile %d: %w", t.Id, err)
+		}
+		ts.Tiles = append(ts.Tiles, &TileConfig{
+			Id:         t.Id,
+			Properties: props,
+			Animation:  t.Animation,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package tiledgrid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestLoadTMX loads testdata/library.tmx, a copy of res/maps/library.json
+// saved by Tiled 1.9 with its objects in a group layer, and checks it loads
+// the same as the JSON map.
+func TestLoadTMX(t *testing.T) {
+	want, err := LoadTileGrid(os.DirFS("../res/maps"), "library.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadTileGrid(os.DirFS("testdata"), "library.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Width != want.Width || got.Height != want.Height || got.TileWidth != want.TileWidth || got.TileHeight != want.TileHeight {
+		t.Errorf("got %dx%d map of %dx%d tiles, want %dx%d of %dx%d", got.Width, got.Height, got.TileWidth, got.TileHeight,
+			want.Width, want.Height, want.TileWidth, want.TileHeight)
+	}
+	if len(got.Layers) != len(want.Layers) {
+		t.Fatalf("got %d layers, want %d", len(got.Layers), len(want.Layers))
+	}
+	for i, l := range got.Layers {
+		w := want.Layers[i]
+		if l.Name != w.Name || l.Type != w.Type {
+			t.Errorf("layer %d: got %s %q, want %s %q", i, l.Type, l.Name, w.Type, w.Name)
+		}
+		if !reflect.DeepEqual(l.Data, w.Data) {
+			t.Errorf("layer %q: data differs", l.Name)
+		}
+		if !reflect.DeepEqual(l.Objects, w.Objects) {
+			t.Errorf("layer %q: got objects %+v, want %+v", l.Name, l.Objects, w.Objects)
+		}
+	}
+
+	if len(got.TileSet) != 1 || len(want.TileSet) != 1 {
+		t.Fatalf("got %d and %d tilesets, want 1", len(got.TileSet), len(want.TileSet))
+	}
+	gotTS, wantTS := *got.TileSet[0], *want.TileSet[0]
+	gotTS.source, wantTS.source = "", ""
+	if !reflect.DeepEqual(gotTS, wantTS) {
+		t.Errorf("got tileset %+v, want %+v", gotTS, wantTS)
+	}
+	if !reflect.DeepEqual(got.TileTypes(), want.TileTypes()) {
+		t.Error("tile types differ")
+	}
+	if !reflect.DeepEqual(got.GetObjectData(), want.GetObjectData()) {
+		t.Error("object data differs")
+	}
+}
+
+func TestIsXML(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{`<?xml version="1.0"?><map/>`, true},
+		{"\n  <map/>", true},
+		{`{"width": 1}`, false},
+		{"  ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isXML([]byte(tt.data)); got != tt.want {
+			t.Errorf("isXML(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+// testTileSet is a tileset with two wall tiles for maps written in tests.
+const testTileSet = `<tileset name="test" tilewidth="16" tileheight="16" tilecount="2" columns="2">
+ <image source="test.png" width="32" height="16"/>
+ <tile id="0"><properties><property name="block" type="bool" value="true"/></properties></tile>
+ <tile id="1"><properties><property name="wallTex" value="wall-1"/></properties></tile>
+</tileset>`
+
+// testTMX wraps layers in a 3x2 map using testTileSet.
+func testTMX(layers string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<map width="3" height="2" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" source="test.tsx"/>
+` + layers + `
+</map>`
+}
+
+func loadTestTMX(t *testing.T, tmx string) (*TiledGrid, error) {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"maps/test.tmx": {Data: []byte(tmx)},
+		"maps/test.tsx": {Data: []byte(testTileSet)},
+	}
+	return LoadTileGrid(fsys, "maps/test.tmx")
+}
+
+// encodeTestData encodes gids as base64 layer data the way Tiled does, with
+// compression "", "zlib" or "gzip".
+func encodeTestData(t *testing.T, gids []int, compression string) string {
+	t.Helper()
+	var raw bytes.Buffer
+	for _, gid := range gids {
+		binary.Write(&raw, binary.LittleEndian, uint32(gid))
+	}
+	var b bytes.Buffer
+	switch compression {
+	case "":
+		b = raw
+	case "zlib":
+		w := zlib.NewWriter(&b)
+		w.Write(raw.Bytes())
+		w.Close()
+	case "gzip":
+		w := gzip.NewWriter(&b)
+		w.Write(raw.Bytes())
+		w.Close()
+	default:
+		t.Fatalf("can't compress with %q", compression)
+	}
+	return base64.StdEncoding.EncodeToString(b.Bytes())
+}
+
+func TestTMXData(t *testing.T) {
+	// tile 2 flipped horizontally keeps its flag
+	gids := []int{1, 2, 0, 0, 1, 2 | flippedHorizontallyFlag}
+	var xmlTiles strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&xmlTiles, `<tile gid="%d"/>`, gid)
+	}
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"xml", `<data>` + xmlTiles.String() + `</data>`},
+		{"csv", "<data encoding=\"csv\">\n1,2,0,\n0,1,2147483650\n</data>"},
+		{"base64", `<data encoding="base64">` + encodeTestData(t, gids, "") + `</data>`},
+		{"zlib", `<data encoding="base64" compression="zlib">` + encodeTestData(t, gids, "zlib") + `</data>`},
+		{"gzip", `<data encoding="base64" compression="gzip">` + encodeTestData(t, gids, "gzip") + `</data>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg, err := loadTestTMX(t, testTMX(`<layer name="walls" width="3" height="2">`+tt.data+`</layer>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tg.Layers[0].Data; !reflect.DeepEqual(got, gids) {
+				t.Errorf("got data %v, want %v", got, gids)
+			}
+			if td := tg.GetTileData(2, 1); td.WallTex != "wall-1" || !td.Flip.Horizontal {
+				t.Errorf("got tile %+v at 2,1, want wall-1 flipped horizontally", td)
+			}
+		})
+	}
+}
+
+func TestTMXGroups(t *testing.T) {
+	tg, err := loadTestTMX(t, testTMX(`
+ <layer name="floor" width="3" height="2"><data encoding="csv">2,2,2,2,2,2</data></layer>
+ <group name="outer">
+  <imagelayer name="backdrop"><image source="sky.png"/></imagelayer>
+  <group name="inner">
+   <layer name="walls" width="3" height="2"><data encoding="csv">1,0,1,0,1,0</data></layer>
+  </group>
+  <objectgroup name="things"><object id="1" name="barrel" type="scenery" x="16" y="0"/></objectgroup>
+ </group>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range tg.Layers {
+		names = append(names, l.Name)
+	}
+	if want := []string{"floor", "walls", "things"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got layers %q, want %q", names, want)
+	}
+	if !tg.GetLayerTileData("walls", 0, 0).Block {
+		t.Error("wall in a nested group doesn't block")
+	}
+	if ods := tg.GetObjectData(); len(ods) != 1 || ods[0].Name != "barrel" {
+		t.Errorf("got objects %+v, want the barrel", ods)
+	}
+}
+
+func TestTMXObjectType(t *testing.T) {
+	tg, err := loadTestTMX(t, testTMX(`<objectgroup name="things">
+  <object id="1" name="old" type="scenery" x="0" y="0"/>
+  <object id="2" name="new" class="pickup" x="16" y="0"/>
+ </objectgroup>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ods := tg.GetObjectData()
+	if len(ods) != 2 {
+		t.Fatalf("got %d objects, want 2", len(ods))
+	}
+	if ods[0].ObjectType != "scenery" || ods[1].ObjectType != "pickup" {
+		t.Errorf("got types %q and %q, want scenery and pickup from the class", ods[0].ObjectType, ods[1].ObjectType)
+	}
+}
+
+func TestTMXErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers string
+		want   string
+	}{
+		{
+			name:   "embedded tileset",
+			layers: `<tileset firstgid="3" name="inline" tilewidth="16" tileheight="16"/>`,
+			want:   "tileset with firstgid 3: embedded tilesets are not supported",
+		},
+		{
+			name:   "bad csv",
+			layers: `<layer name="walls" width="3" height="2"><data encoding="csv">1,x,1,0,1,0</data></layer>`,
+			want:   `layer "walls": decoding csv data`,
+		},
+		{
+			name:   "short csv",
+			layers: `<layer name="walls" width="3" height="2"><data encoding="csv">1,0,1,0,1</data></layer>`,
+			want:   `layer "walls": 5 tiles of data for a 3x2 layer`,
+		},
+		{
+			name:   "unknown encoding",
+			layers: `<layer name="walls" width="3" height="2"><data encoding="hex">00</data></layer>`,
+			want:   `layer "walls": unsupported data encoding "hex"`,
+		},
+		{
+			name:   "bad property in a group",
+			layers: `<group name="g"><objectgroup name="things"><object id="1" name="lamp"><properties><property name="lit" type="bool" value="maybe"/></properties></object></objectgroup></group>`,
+			want:   `group "g": object "lamp": property "lit"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestTMX(t, testTMX(tt.layers))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertProperties(t *testing.T) {
+	props := []tmxProperty{
+		{Name: "lit", Type: "bool", Value: "true"},
+		{Name: "count", Type: "int", Value: "3"},
+		{Name: "speed", Type: "float", Value: "1.5"},
+		{Name: "target", Type: "object", Value: "12"},
+		{Name: "colour", Type: "color", Value: "#ff00ff00"},
+		{Name: "note", Text: "first line\nsecond line"},
+		{Name: "light", Type: "class", PropertyType: "Light", Members: []tmxProperty{
+			{Name: "radius", Type: "float", Value: "2.5"},
+			{Name: "on", Type: "bool", Value: "false"},
+		}},
+	}
+	got, err := convertProperties(props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Properties{
+		{Name: "lit", Type: "bool", Value: true},
+		{Name: "count", Type: "int", Value: 3.0},
+		{Name: "speed", Type: "float", Value: 1.5},
+		{Name: "target", Type: "object", Value: 12.0},
+		{Name: "colour", Type: "color", Value: "#ff00ff00"},
+		{Name: "note", Type: "string", Value: "first line\nsecond line"},
+		{Name: "light", Type: "class", PropertyType: "Light", Value: map[string]interface{}{"radius": 2.5, "on": false}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", describeProperties(got), describeProperties(want))
+	}
+
+	for _, bad := range []tmxProperty{
+		{Name: "lit", Type: "bool", Value: "yes please"},
+		{Name: "count", Type: "int", Value: "three"},
+		{Name: "light", Type: "class", Members: []tmxProperty{{Name: "on", Type: "bool", Value: "?"}}},
+	} {
+		if _, err := convertProperties([]tmxProperty{bad}); err == nil {
+			t.Errorf("no error converting %s %q", bad.Type, bad.Name)
+		}
+	}
+}
+
+func describeProperties(props Properties) string {
+	var s []string
+	for _, p := range props {
+		s = append(s, fmt.Sprintf("%s %s(%s)=%#v", p.Name, p.Type, p.PropertyType, p.Value))
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
@@ -0,0 +1,66 @@
+package tiledgrid
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// WorldExt is the file extension of Tiled world files.
+const WorldExt = ".world"
+
+// TiledWorld is a Tiled world file, which lays maps out next to each other
+// so they can be edited and played as one large level.
+type TiledWorld struct {
+	Maps     []*WorldMap `json:"maps"`
+	Patterns []struct {
+		RegExp string `json:"regexp"`
+	} `json:"patterns"`
+	Type string `json:"type"`
+}
+
+// WorldMap is a map placed in a world. X and Y are the pixel position of
+// the map's top left corner in the world. FileName is resolved against the
+// world file's directory when loaded, so it names the map in the same fs.
+type WorldMap struct {
+	FileName string  `json:"fileName"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+}
+
+// LoadWorld loads the world file name from fsys. Only worlds listing their
+// maps are supported, not ones that find maps by pattern.
+func LoadWorld(fsys fs.FS, name string) (*TiledWorld, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("opening world file: %w", err)
+	}
+	var world TiledWorld
+	if err := decodeJSON(data, &world); err != nil {
+		return nil, fmt.Errorf("parsing world file %s: %w", name, err)
+	}
+	if len(world.Maps) == 0 {
+		if len(world.Patterns) > 0 {
+			return nil, fmt.Errorf("world file %s: maps found by pattern are not supported", name)
+		}
+		return nil, fmt.Errorf("world file %s has no maps", name)
+	}
+	for _, m := range world.Maps {
+		m.FileName = path.Join(path.Dir(name), m.FileName)
+	}
+	return &world, nil
+}
+
+// Map returns the map in the world loaded from fileName, or nil.
+func (w *TiledWorld) Map(fileName string) *WorldMap {
+	fileName = path.Clean(fileName)
+	for _, m := range w.Maps {
+		if strings.EqualFold(m.FileName, fileName) {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package raycast
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultFOV is the horizontal field of view, in degrees, of a camera plane
+// half as wide as the camera is far from it.
+const DefaultFOV = 53.13010235415598
+
+// View sets the internal resolution frames are drawn at and how much of the
+// world they show.
+type View struct {
+	// size of the frame in pixels
+	Width  int
+	Height int
+	// width over height of the frame as shown on screen, 0 for square
+	// pixels
+	Aspect float64
+	// horizontal field of view in degrees
+	FOV float64
+}
+
+// Views are the view modes the game ships with.
+var Views = map[string]View{
+	"classic": DefaultView(),
+	// 16:9 with the same vertical view as classic
+	"widescreen": {Width: 456, Height: 256, FOV: 83.37811697077711},
+	// chunky pixels stretched to 4:3
+	"retro": {Width: 160, Height: 100, Aspect: 4.0 / 3.0, FOV: 60},
+}
+
+func DefaultView() View {
+	return View{
+		Width:  ScreenWidth,
+		Height: ScreenHeight,
+		FOV:    DefaultFOV,
+	}
+}
+
+func (v View) validate() error {
+	if v.Width < 64 || v.Height < 64 || v.Width > 4096 || v.Height > 4096 {
+		return fmt.Errorf("view: %dx%d is not between 64x64 and 4096x4096", v.Width, v.Height)
+	}
+	if v.Aspect < 0 {
+		return fmt.Errorf("view: negative aspect ratio %g", v.Aspect)
+	}
+	if v.FOV <= 0 || v.FOV >= 180 {
+		return fmt.Errorf("view: field of view %g is not between 0 and 180 degrees", v.FOV)
+	}
+	return nil
+}
+
+// aspect is the width over height of the frame on screen.
+func (v View) aspect() float64 {
+	if v.Aspect == 0 {
+		return float64(v.Width) / float64(v.Height)
+	}
+	return v.Aspect
+}
+
+// planeLength is half the width of the camera plane one unit in front of the
+// camera.
+func (v View) planeLength() float64 {
+	return fovPlaneLength(v.FOV)
+}
+
+func fovPlaneLength(fov float64) float64 {
+	return math.Tan(fov * math.Pi / 360)
+}
+
+// scale returns how many pixels across and down a world unit covers one unit
+// in front of the camera. They differ when pixels aren't shown square.
+func (v View) scale() (across float64, down float64) {
+	planeLength := v.planeLength()
+	across = float64(v.Width) / (2 * planeLength)
+	down = v.aspect() * float64(v.Height) / (2 * planeLength)
+	return across, down
+}
+
+// screenSize is the size to lay the screen out at so the frame shows with
+// its aspect ratio.
+func (v View) screenSize() (int, int) {
+	if v.Aspect == 0 {
+		return v.Width, v.Height
+	}
+	return v.Width, int(float64(v.Width)/v.Aspect + 0.5)
+}
@@ -0,0 +1,274 @@
+package wolfmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"raycast.com/tiledgrid"
+)
+
+const (
+	tileSize       = 16
+	tileSetColumns = 8
+	floorKey       = "floor"
+)
+
+// CodeTable says what the codes in each plane become. Codes are written as
+// a single number or an inclusive range such as "1-63", and a single code
+// takes precedence over a range holding it. Ranges may not overlap each
+// other, nor single codes repeat.
+type CodeTable struct {
+	// Floor holds the tile properties of open ground
+	Floor map[string]interface{} `json:"floor"`
+	// Tiles maps wall plane codes to tile properties such as block, door,
+	// north, locked and wallTex
+	Tiles map[string]map[string]interface{} `json:"tiles"`
+	// Open lists wall plane codes that are plain floor, such as the area
+	// numbers Wolfenstein 3D uses for sound propagation
+	Open []string `json:"open"`
+	// Objects maps object plane codes to the objects placed there
+	Objects map[string]*ObjectCode `json:"objects"`
+
+	tiles   map[uint16]string
+	open    map[uint16]bool
+	objects map[uint16]*ObjectCode
+}
+
+// ObjectCode is an object placed for a code, named the way it would be in
+// Tiled: a type such as enemy, pickup, scenery or level and a name from
+// entities.json.
+type ObjectCode struct {
+	Type       string                 `json:"type"`
+	Name       string                 `json:"name"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// LoadCodeTable reads a code table from a JSON file in fsys.
+func LoadCodeTable(fsys fs.FS, name string) (*CodeTable, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("opening code table: %w", err)
+	}
+	var t CodeTable
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing code table %s: %w", name, err)
+	}
+	if err := t.expand(); err != nil {
+		return nil, fmt.Errorf("code table %s: %w", name, err)
+	}
+	return &t, nil
+}
+
+// expand fills in the lookups by code, putting ranges in first so single
+// codes overwrite them.
+func (t *CodeTable) expand() error {
+	t.tiles = map[uint16]string{}
+	t.open = map[uint16]bool{}
+	t.objects = map[uint16]*ObjectCode{}
+
+	tileKeys := make([]string, 0, len(t.Tiles))
+	for key := range t.Tiles {
+		tileKeys = append(tileKeys, key)
+	}
+	sort.Strings(tileKeys)
+	objectKeys := make([]string, 0, len(t.Objects))
+	for key, obj := range t.Objects {
+		if obj.Type == "" {
+			return fmt.Errorf("object code %s has no type", key)
+		}
+		objectKeys = append(objectKeys, key)
+	}
+	sort.Strings(objectKeys)
+
+	for _, ranges := range []bool{true, false} {
+		// which key a code came from, to catch two keys of the same kind
+		// giving it different meanings
+		tileOwners := map[uint16]string{}
+		for _, key := range tileKeys {
+			key := key
+			if err := eachCode(key, ranges, func(c uint16) error {
+				if other, ok := tileOwners[c]; ok {
+					return fmt.Errorf("wall code %d is in both %q and %q", c, other, key)
+				}
+				tileOwners[c] = key
+				t.tiles[c] = key
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		for _, key := range t.Open {
+			if err := eachCode(key, ranges, func(c uint16) error {
+				t.open[c] = true
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		objectOwners := map[uint16]string{}
+		for _, key := range objectKeys {
+			key, obj := key, t.Objects[key]
+			if err := eachCode(key, ranges, func(c uint16) error {
+				if other, ok := objectOwners[c]; ok {
+					return fmt.Errorf("object code %d is in both %q and %q", c, other, key)
+				}
+				objectOwners[c] = key
+				t.objects[c] = obj
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eachCode calls f for every code in key, if key is a range and ranges is
+// set or key is a single code and ranges is not, stopping at the first
+// error.
+func eachCode(key string, ranges bool, f func(uint16) error) error {
+	first, last := key, key
+	if i := strings.Index(key, "-"); i >= 0 {
+		first, last = key[:i], key[i+1:]
+	}
+	if (first != last) != ranges {
+		return nil
+	}
+	from, err := strconv.ParseUint(strings.TrimSpace(first), 10, 16)
+	if err != nil {
+		return fmt.Errorf("code %q is not a number or range", key)
+	}
+	to, err := strconv.ParseUint(strings.TrimSpace(last), 10, 16)
+	if err != nil || to < from {
+		return fmt.Errorf("code %q is not a number or range", key)
+	}
+	for c := from; c <= to; c++ {
+		if err := f(uint16(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TiledGrid lays the map out as a Tiled map with one tile layer and one
+// object layer, using a tileset to be saved as tileSetSource. Codes the
+// table has no entry for are listed in the returned problems; unknown wall
+// codes become floor and unknown objects are left out.
+func (t *CodeTable) TiledGrid(m *Map, tileSetSource string) (*tiledgrid.TiledGrid, *tiledgrid.TileSet, []string) {
+	ids := map[string]int{}
+	var tiles []*tiledgrid.TileConfig
+	tileId := func(key string, props map[string]interface{}) int {
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := len(tiles)
+		ids[key] = id
+		tiles = append(tiles, &tiledgrid.TileConfig{Id: id, Properties: properties(props)})
+		return id
+	}
+
+	unknownTiles := map[uint16]int{}
+	unknownObjects := map[uint16]int{}
+	data := make([]int, m.Width*m.Height)
+	objects := []tiledgrid.TiledObject{}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			code := m.Code(WallPlane, x, y)
+			if key, ok := t.tiles[code]; ok {
+				data[y*m.Width+x] = 1 + tileId(key, t.Tiles[key])
+			} else {
+				if !t.open[code] {
+					unknownTiles[code]++
+				}
+				data[y*m.Width+x] = 1 + tileId(floorKey, t.Floor)
+			}
+
+			code = m.Code(ObjectPlane, x, y)
+			if code == 0 {
+				continue
+			}
+			obj, ok := t.objects[code]
+			if !ok {
+				unknownObjects[code]++
+				continue
+			}
+			objects = append(objects, tiledgrid.TiledObject{
+				Id:         len(objects) + 1,
+				Name:       obj.Name,
+				Type:       obj.Type,
+				X:          float64(x * tileSize),
+				Y:          float64(y * tileSize),
+				Width:      tileSize,
+				Height:     tileSize,
+				Properties: properties(obj.Properties),
+			})
+		}
+	}
+
+	grid := &tiledgrid.TiledGrid{
+		Width:      m.Width,
+		Height:     m.Height,
+		TileWidth:  tileSize,
+		TileHeight: tileSize,
+		Layers: []*tiledgrid.Layer{
+			{Name: "Tile Layer 1", Type: "tilelayer", Width: m.Width, Height: m.Height, Data: data},
+			{Name: "Object Layer 1", Type: "objectgroup", Objects: objects},
+		},
+		TileSetReferences: []*tiledgrid.TileSetReference{{Source: tileSetSource, FirstGid: 1}},
+	}
+	if name := strings.TrimSpace(m.Name); name != "" {
+		grid.Properties = tiledgrid.Properties{{Name: "name", Type: "string", Value: name}}
+	}
+
+	tileSet := tiledgrid.NewTileSet(tileSetSource, tiles, tileSize, tileSetColumns)
+
+	var problems []string
+	for _, code := range sortedCodes(unknownTiles) {
+		problems = append(problems, fmt.Sprintf("no tile for wall code %d (%d tiles)", code, unknownTiles[code]))
+	}
+	for _, code := range sortedCodes(unknownObjects) {
+		problems = append(problems, fmt.Sprintf("no object for object code %d (%d tiles)", code, unknownObjects[code]))
+	}
+	return grid, tileSet, problems
+}
+
+// properties converts JSON values to typed Tiled properties, sorted by name.
+func properties(values map[string]interface{}) tiledgrid.Properties {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := tiledgrid.Properties{}
+	for _, name := range names {
+		prop := &tiledgrid.TileConfigProp{Name: name, Value: values[name]}
+		switch v := values[name].(type) {
+		case bool:
+			prop.Type = "bool"
+		case float64:
+			prop.Type = "float"
+			if v == math.Trunc(v) {
+				prop.Type = "int"
+			}
+		default:
+			prop.Type = "string"
+		}
+		props = append(props, prop)
+	}
+	return props
+}
+
+func sortedCodes(counts map[uint16]int) []uint16 {
+	codes := make([]uint16, 0, len(counts))
+	for c := range counts {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
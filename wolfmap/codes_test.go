@@ -0,0 +1,144 @@
+package wolfmap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testCodeTable = `{
+	"floor": {"floorTex": "floor", "ceilingTex": "ceiling"},
+	"tiles": {
+		"1-63": {"block": true, "wallTex": "wall-1"},
+		"2": {"block": true, "wallTex": "wall-2"}
+	},
+	"open": ["106-143"],
+	"objects": {
+		"19": {"type": "level", "name": "start", "properties": {"dir": "north"}},
+		"24": {"type": "scenery", "name": "barrel"}
+	}
+}`
+
+func TestCodeTableTiledGrid(t *testing.T) {
+	fsys := fstest.MapFS{"codes.json": {Data: []byte(testCodeTable)}}
+	table, err := LoadCodeTable(fsys, "codes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Map{
+		Name:   "Codes",
+		Width:  5,
+		Height: 1,
+		Planes: [numPlanes][]uint16{
+			// in the range, the single code overriding it, open, unknown
+			WallPlane:   {1, 2, 106, 300, 1},
+			ObjectPlane: {0, 19, 24, 999, 0},
+		},
+	}
+
+	grid, tileSet, problems := table.TiledGrid(m, "codes-tiles.json")
+
+	wantProblems := []string{
+		"no tile for wall code 300 (1 tiles)",
+		"no object for object code 999 (1 tiles)",
+	}
+	if !reflect.DeepEqual(problems, wantProblems) {
+		t.Errorf("got problems %q, want %q", problems, wantProblems)
+	}
+
+	// tiles are numbered as they're first used, and unknown codes are floor
+	if data := grid.Layers[0].Data; !reflect.DeepEqual(data, []int{1, 2, 3, 3, 1}) {
+		t.Errorf("got tile data %v, want [1 2 3 3 1]", data)
+	}
+	wantTex := []struct {
+		name  string
+		value string
+	}{
+		{"wallTex", "wall-1"},
+		{"wallTex", "wall-2"},
+		{"floorTex", "floor"},
+	}
+	if len(tileSet.Tiles) != len(wantTex) {
+		t.Fatalf("got %d tiles, want %d", len(tileSet.Tiles), len(wantTex))
+	}
+	for i, want := range wantTex {
+		got, err := tileSet.Tiles[i].Properties.String(want.name, "")
+		if err != nil || got != want.value {
+			t.Errorf("tile %d: got %s %q (%v), want %q", i, want.name, got, err, want.value)
+		}
+	}
+	if tileSet.ImageFileName != "codes-tiles.png" {
+		t.Errorf("got tileset image %q, want codes-tiles.png", tileSet.ImageFileName)
+	}
+
+	// the unknown object is left out
+	objects := grid.Layers[1].Objects
+	if len(objects) != 2 {
+		t.Fatalf("got %d objects, want 2", len(objects))
+	}
+	start, barrel := objects[0], objects[1]
+	if start.Name != "start" || start.Type != "level" || start.X != tileSize || start.Y != 0 {
+		t.Errorf("got start %s %s at %g,%g, want level start at 16,0", start.Type, start.Name, start.X, start.Y)
+	}
+	if dir, _ := start.Properties.String("dir", ""); dir != "north" {
+		t.Errorf("got start dir %q, want north", dir)
+	}
+	if barrel.Name != "barrel" || barrel.Type != "scenery" || barrel.X != 2*tileSize {
+		t.Errorf("got %s %s at %g, want scenery barrel at 32", barrel.Type, barrel.Name, barrel.X)
+	}
+
+	if name, _ := grid.Properties.String("name", ""); name != "Codes" {
+		t.Errorf("got map name %q, want Codes", name)
+	}
+}
+
+func TestCodeTableOverlaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  string
+	}{
+		{
+			name:  "single codes in ranges",
+			table: `{"tiles": {"1-10": {"wallTex": "a"}, "5": {"wallTex": "b"}, "11-20": {"wallTex": "c"}}}`,
+		},
+		{
+			name:  "overlapping wall ranges",
+			table: `{"tiles": {"1-10": {"wallTex": "a"}, "8-20": {"wallTex": "b"}}}`,
+			want:  `wall code 8 is in both "1-10" and "8-20"`,
+		},
+		{
+			name:  "repeated wall code",
+			table: `{"tiles": {"5": {"wallTex": "a"}, "05": {"wallTex": "b"}}}`,
+			want:  `wall code 5 is in both "05" and "5"`,
+		},
+		{
+			name:  "overlapping object ranges",
+			table: `{"objects": {"23-30": {"type": "scenery", "name": "a"}, "30-40": {"type": "scenery", "name": "b"}}}`,
+			want:  `object code 30 is in both "23-30" and "30-40"`,
+		},
+		{
+			name:  "same codes in different planes",
+			table: `{"tiles": {"1-10": {"wallTex": "a"}}, "open": ["5-15"], "objects": {"1-10": {"type": "scenery", "name": "a"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"codes.json": {Data: []byte(tt.table)}}
+			table, err := LoadCodeTable(fsys, "codes.json")
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, single := table.Tiles["5"]; single && table.tiles[5] != "5" {
+					t.Errorf("code 5 is %q, want the single code 5 over its range", table.tiles[5])
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package wolfmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Carmack compression marks back references with these in the high byte of
+// a word. A near pointer is followed by a byte holding how many words back
+// to copy from, a far pointer by a word holding the offset from the start.
+const (
+	nearTag = 0xa7
+	farTag  = 0xa8
+)
+
+var errTruncated = errors.New("compressed data is truncated")
+
+// decarmack expands Carmack compressed data. The first word of data is the
+// expanded length in bytes.
+func decarmack(data []byte) ([]uint16, error) {
+	if len(data) < 2 {
+		return nil, errTruncated
+	}
+	length := int(binary.LittleEndian.Uint16(data)) / 2
+	out := make([]uint16, 0, length)
+	i := 2
+	readByte := func() (int, error) {
+		if i >= len(data) {
+			return 0, errTruncated
+		}
+		i++
+		return int(data[i-1]), nil
+	}
+	readWord := func() (int, error) {
+		if i+1 >= len(data) {
+			return 0, errTruncated
+		}
+		i += 2
+		return int(binary.LittleEndian.Uint16(data[i-2:])), nil
+	}
+
+	for len(out) < length {
+		word, err := readWord()
+		if err != nil {
+			return nil, err
+		}
+		count, tag := word&0xff, word>>8
+		if tag != nearTag && tag != farTag {
+			out = append(out, uint16(word))
+			continue
+		}
+		if count == 0 {
+			// a literal word that happens to look like a pointer, with its
+			// low byte stored next
+			low, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, uint16(tag<<8|low))
+			continue
+		}
+		var from int
+		if tag == nearTag {
+			back, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			from = len(out) - back
+		} else {
+			if from, err = readWord(); err != nil {
+				return nil, err
+			}
+		}
+		if from < 0 || from >= len(out) {
+			return nil, fmt.Errorf("back reference to word %d with only %d words expanded", from, len(out))
+		}
+		// copy one word at a time, since the source can overlap what's
+		// being written
+		for n := 0; n < count; n++ {
+			out = append(out, out[from+n])
+		}
+	}
+	return out[:length], nil
+}
+
+// derlew expands run length encoded words. The first word is the expanded
+// length in bytes, and tag followed by a count and a value stands for count
+// copies of the value.
+func derlew(words []uint16, tag uint16) ([]uint16, error) {
+	if len(words) < 1 {
+		return nil, errTruncated
+	}
+	length := int(words[0]) / 2
+	out := make([]uint16, 0, length)
+	for i := 1; len(out) < length; {
+		if i >= len(words) {
+			return nil, errTruncated
+		}
+		if words[i] != tag {
+			out = append(out, words[i])
+			i++
+			continue
+		}
+		if i+2 >= len(words) {
+			return nil, errTruncated
+		}
+		for n := 0; n < int(words[i+1]); n++ {
+			out = append(out, words[i+2])
+		}
+		i += 3
+	}
+	return out[:length], nil
+}
+
+func bytesToWords(data []byte) []uint16 {
+	words := make([]uint16, len(data)/2)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return words
+}
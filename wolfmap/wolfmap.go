@@ -0,0 +1,129 @@
+// Package wolfmap reads maps in the layout used by Wolfenstein 3D and games
+// built on the same engine: a MAPHEAD file listing where each map starts
+// and a GAMEMAPS file holding the compressed planes of tile codes.
+package wolfmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+const (
+	// maps a MAPHEAD file has room for
+	maxMaps = 100
+	// wall plane, object plane and a third plane most games leave empty
+	numPlanes = 3
+	// bytes in the header at the start of each map
+	mapHeaderSize = 38
+)
+
+// Plane indexes.
+const (
+	WallPlane   = 0
+	ObjectPlane = 1
+)
+
+// Map is one level, with a code for every tile in each plane.
+type Map struct {
+	Name   string
+	Width  int
+	Height int
+	Planes [numPlanes][]uint16
+}
+
+// Code returns the code at x, y in a plane, or 0 outside the map.
+func (m *Map) Code(plane int, x int, y int) uint16 {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height || len(m.Planes[plane]) == 0 {
+		return 0
+	}
+	return m.Planes[plane][y*m.Width+x]
+}
+
+// Options says how the maps were stored.
+type Options struct {
+	// Carmack is set when planes are Carmack compressed on top of RLEW, as
+	// in GAMEMAPS files. MAPTEMP files written by the map editor are RLEW
+	// only.
+	Carmack bool
+}
+
+// LoadMaps reads every map listed in the map head file from the game maps
+// file, both in fsys.
+func LoadMaps(fsys fs.FS, mapHead string, gameMaps string, opts Options) ([]*Map, error) {
+	head, err := fs.ReadFile(fsys, mapHead)
+	if err != nil {
+		return nil, fmt.Errorf("opening map head file: %w", err)
+	}
+	data, err := fs.ReadFile(fsys, gameMaps)
+	if err != nil {
+		return nil, fmt.Errorf("opening game maps file: %w", err)
+	}
+	if len(head) < 2 {
+		return nil, fmt.Errorf("map head file %s is too short", mapHead)
+	}
+	tag := binary.LittleEndian.Uint16(head)
+
+	var maps []*Map
+	for i := 0; i < maxMaps && 2+i*4+4 <= len(head); i++ {
+		offset := binary.LittleEndian.Uint32(head[2+i*4:])
+		if offset == 0 || offset == 0xffffffff {
+			continue
+		}
+		m, err := readMap(data, int(offset), tag, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: map %d: %w", gameMaps, i, err)
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+func readMap(data []byte, offset int, tag uint16, opts Options) (*Map, error) {
+	if offset+mapHeaderSize > len(data) {
+		return nil, fmt.Errorf("header at %d is past the end of the file", offset)
+	}
+	header := data[offset : offset+mapHeaderSize]
+	m := &Map{
+		Width:  int(binary.LittleEndian.Uint16(header[18:])),
+		Height: int(binary.LittleEndian.Uint16(header[20:])),
+		Name:   string(header[22:38]),
+	}
+	if i := strings.IndexByte(m.Name, 0); i >= 0 {
+		m.Name = m.Name[:i]
+	}
+
+	for p := 0; p < numPlanes; p++ {
+		start := int(binary.LittleEndian.Uint32(header[p*4:]))
+		length := int(binary.LittleEndian.Uint16(header[12+p*2:]))
+		if start == 0 || length == 0 {
+			continue
+		}
+		if start+length > len(data) {
+			return nil, fmt.Errorf("plane %d is past the end of the file", p)
+		}
+		plane, err := expandPlane(data[start:start+length], tag, opts)
+		if err != nil {
+			return nil, fmt.Errorf("plane %d: %w", p, err)
+		}
+		if len(plane) < m.Width*m.Height {
+			return nil, fmt.Errorf("plane %d has %d tiles, want %d", p, len(plane), m.Width*m.Height)
+		}
+		m.Planes[p] = plane[:m.Width*m.Height]
+	}
+	return m, nil
+}
+
+func expandPlane(data []byte, tag uint16, opts Options) ([]uint16, error) {
+	var words []uint16
+	var err error
+	if opts.Carmack {
+		if words, err = decarmack(data); err != nil {
+			return nil, err
+		}
+	} else {
+		words = bytesToWords(data)
+	}
+	return derlew(words, tag)
+}
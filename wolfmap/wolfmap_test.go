@@ -0,0 +1,109 @@
+package wolfmap
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// codes of the characters drawn in testdata/maps.txt
+var (
+	testWallCodes = map[rune]uint16{
+		'#': 1, 'B': 9, 'G': 17, '-': 90, '|': 91, 'L': 92, 'l': 93,
+	}
+	testObjectCodes = map[rune]uint16{
+		'^': 19, '>': 20, 'v': 21, '<': 22, 'b': 24, 'k': 43,
+		'a': 49, 'h': 48, 'e': 108, 'E': 99, 'p': 0xa7a8,
+	}
+)
+
+const testFloorCode = 106
+
+func TestLoadMaps(t *testing.T) {
+	want := readTestMaps(t, "testdata/maps.txt")
+	tests := []struct {
+		name     string
+		mapHead  string
+		gameMaps string
+		opts     Options
+	}{
+		{name: "carmack", mapHead: "MAPHEAD.TST", gameMaps: "GAMEMAPS.TST", opts: Options{Carmack: true}},
+		{name: "rlew", mapHead: "MAPTHEAD.TST", gameMaps: "MAPTEMP.TST", opts: Options{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maps, err := LoadMaps(os.DirFS("testdata"), tt.mapHead, tt.gameMaps, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(maps) != len(want) {
+				t.Fatalf("got %d maps, want %d", len(maps), len(want))
+			}
+			for i, m := range maps {
+				w := want[i]
+				if m.Name != w.Name || m.Width != w.Width || m.Height != w.Height {
+					t.Errorf("map %d is %q %dx%d, want %q %dx%d", i, m.Name, m.Width, m.Height, w.Name, w.Width, w.Height)
+					continue
+				}
+				for p := 0; p < numPlanes; p++ {
+					for y := 0; y < m.Height; y++ {
+						for x := 0; x < m.Width; x++ {
+							if got, code := m.Code(p, x, y), w.Code(p, x, y); got != code {
+								t.Errorf("map %d plane %d at %d,%d: got code %d, want %d", i, p, x, y, got, code)
+							}
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+var testMapHeader = regexp.MustCompile(`^Map (\d+) "(.*)", (\d+)x(\d+):$`)
+
+// readTestMaps reads the maps drawn in the description of the test data.
+// The third plane is left empty.
+func readTestMaps(t *testing.T, name string) []*Map {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var maps []*Map
+	var m *Map
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := testMapHeader.FindStringSubmatch(line); match != nil {
+			width, _ := strconv.Atoi(match[3])
+			height, _ := strconv.Atoi(match[4])
+			m = &Map{Name: match[2], Width: width, Height: height}
+			maps = append(maps, m)
+			continue
+		}
+		if m == nil || line == "" || len(m.Planes[WallPlane]) == m.Width*m.Height {
+			continue
+		}
+		if len(line) != m.Width {
+			t.Fatalf("%s: row %q of map %q is not %d wide", name, line, m.Name, m.Width)
+		}
+		for _, c := range line {
+			wall, ok := testWallCodes[c]
+			if !ok {
+				wall = testFloorCode
+			}
+			m.Planes[WallPlane] = append(m.Planes[WallPlane], wall)
+			m.Planes[ObjectPlane] = append(m.Planes[ObjectPlane], testObjectCodes[c])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) == 0 {
+		t.Fatalf("%s: no maps", name)
+	}
+	return maps
+}
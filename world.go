@@ -1,8 +1,12 @@
 package raycast
 
 import (
+	"io/fs"
 	"math"
 	"math/rand"
+	"sync/atomic"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
 )
 
 const moveAmount = 0.002
@@ -44,42 +48,190 @@ type mapPos struct {
 }
 
 type tile struct {
-	block      bool
-	door       bool
-	north      bool
-	floorTex   string
-	wallTex    string
+	block    bool
+	door     bool
+	north    bool
+	floorTex string
+	wallTex  string
+	// per face wall textures, empty to use wallTex
+	wallTexN   string
+	wallTexS   string
+	wallTexE   string
+	wallTexW   string
 	doorTex    string
 	ceilingTex string
-	seen       bool
-	locked     bool
+	// set once a ray has passed through, read atomically as rays are cast
+	// from several goroutines
+	seen   int32
+	locked bool
+	// how far a door has slid open, from 0 shut to 1 open
+	doorOpen  float64
+	doorState doorState
+	// milliseconds an open door waits before closing
+	doorTimer   float64
+	wallFlip    textureFlip
+	floorFlip   textureFlip
+	ceilingFlip textureFlip
+	// animations of the tile's textures, keyed by the texture they replace,
+	// shared with the other cells placed with the same animated tile
+	animations map[string]*textureAnimation
+}
+
+// setAnimation animates texture with a, or stops it animating if a is nil,
+// copying the animations first since other cells may share them.
+func (t *tile) setAnimation(texture string, a *textureAnimation) {
+	if t.animations[texture] == a {
+		return
+	}
+	animations := make(map[string]*textureAnimation, len(t.animations)+1)
+	for name, other := range t.animations {
+		animations[name] = other
+	}
+	if a == nil {
+		delete(animations, texture)
+	} else {
+		animations[texture] = a
+	}
+	t.animations = animations
+}
+
+// markSeen records that a ray has passed through the tile.
+func (t *tile) markSeen() {
+	atomic.StoreInt32(&t.seen, 1)
+}
+
+func (t *tile) isSeen() bool {
+	return atomic.LoadInt32(&t.seen) != 0
+}
+
+// wallTexture returns the texture for the face of the tile that a ray going
+// in dir hits on side, falling back to wallTex for faces without their own.
+func (t *tile) wallTexture(side int, dir vector) string {
+	var face string
+	if side == 0 {
+		if dir.x > 0 {
+			face = t.wallTexW
+		} else {
+			face = t.wallTexE
+		}
+	} else {
+		if dir.y > 0 {
+			face = t.wallTexN
+		} else {
+			face = t.wallTexS
+		}
+	}
+	if face == "" {
+		return t.wallTex
+	}
+	return face
+}
+
+// textureFlip mirrors or rotates texture coordinates the way Tiled drew the
+// tile.
+type textureFlip struct {
+	horizontal bool
+	vertical   bool
+	diagonal   bool
+}
+
+// apply maps a texture coordinate on screen to the texel to sample.
+func (f textureFlip) apply(x, y int) (int, int) {
+	if f.horizontal {
+		x = TextureWidth - x - 1
+	}
+	if f.vertical {
+		y = TextureHeight - y - 1
+	}
+	if f.diagonal {
+		x, y = y, x
+	}
+	return x, y
 }
 
 type World struct {
-	width       int
-	height      int
-	tiles       [][]*tile
+	// map the world was loaded from, relative to the maps directory
+	mapFile  string
+	settings *levelSettings
+	width    int
+	height   int
+	tiles    [][]*tile
+	doors    []mapPos
+	// milliseconds the world has been running, used to animate tiles
+	clock       float64
 	bullets     []*bullet
 	enemies     []*enemy
 	pickups     []*pickup
 	scenery     []*scenery
 	effects     []*effect
 	portals     []*portal
+	spawns      map[string]placement
 	particles   []*particle
 	player      *player
 	soundPlayer *SoundPlayer
 	debug       *debug
+	// set once the player walks into an exit or the end of the level
+	exit     *levelExit
+	complete bool
 }
 
 type debug struct {
 	passiveMode bool
 }
 
-func NewWorld(level string) *World {
-	l := LoadLevel(level)
+func NewWorld(level string) (*World, error) {
+	return newWorld(level, NewSoundPlayer())
+}
 
+// newWorld loads a level using an existing sound player. Ebiten only allows
+// one audio context, so every level after the first must share it.
+func newWorld(level string, soundPlayer *SoundPlayer) (*World, error) {
+	l, err := LoadLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	w := worldFromLevel(level, l, soundPlayer)
+	sounds := []string{
+		"pickup-health",
+		"pickup-ammo",
+		"pickup-soul",
+		"door",
+		"crack",
+		"thud",
+		"chunk",
+		"player-hurt",
+		"bullet-hit",
+		"enemy-die",
+		"enemy-hurt",
+		"enemy-shoot",
+	}
+	for _, sound := range append(sounds, entityDefs.sounds()...) {
+		if !w.soundPlayer.HasSound(sound) {
+			w.soundPlayer.LoadSound(sound)
+		}
+	}
+	if w.settings.music != "" {
+		w.soundPlayer.PlayMusic(w.settings.music)
+	}
+	return w, nil
+}
+
+// NewWorldFS loads a level from a map file in fsys, with no sound, so frames
+// can be drawn offscreen on machines without audio.
+func NewWorldFS(fsys fs.FS, fileName string) (*World, error) {
+	l, err := LoadLevelFS(fsys, fileName)
+	if err != nil {
+		return nil, err
+	}
+	silent := &SoundPlayer{players: map[string]*audio.Player{}}
+	return worldFromLevel(fileName, l, silent), nil
+}
+
+func worldFromLevel(mapFile string, l *level, soundPlayer *SoundPlayer) *World {
 	w := &World{
-		soundPlayer: NewSoundPlayer(),
+		mapFile:     mapFile,
+		soundPlayer: soundPlayer,
+		settings:    l.settings,
 		tiles:       l.tiles,
 		width:       l.width,
 		height:      l.height,
@@ -88,28 +240,61 @@ func NewWorld(level string) *World {
 		pickups:     l.objectData.pickups,
 		scenery:     l.objectData.scenery,
 		portals:     l.objectData.portals,
+		spawns:      l.objectData.spawns,
 		// temp state
 		bullets:   []*bullet{},
 		effects:   []*effect{},
 		particles: []*particle{},
 		debug:     &debug{},
 	}
-	w.soundPlayer.LoadSound("pickup-health")
-	w.soundPlayer.LoadSound("pickup-ammo")
-	w.soundPlayer.LoadSound("pickup-soul")
-	w.soundPlayer.LoadSound("door")
-	w.soundPlayer.LoadSound("crack")
-	w.soundPlayer.LoadSound("thud")
-	w.soundPlayer.LoadSound("chunk")
-	w.soundPlayer.LoadSound("player-hurt")
-	w.soundPlayer.LoadSound("bullet-hit")
-	w.soundPlayer.LoadSound("enemy-die")
-	w.soundPlayer.LoadSound("enemy-hurt")
-	w.soundPlayer.LoadSound("enemy-shoot")
+	if l.objectData.startDir == "" && l.objectData.hasStart {
+		// no named direction, so face the way the start object is rotated
+		w.player.face(l.objectData.startFacing)
+	}
+	w.findDoors()
+	w.armPortals()
 	return w
 }
 
+// Camera returns where the player is and the direction they're looking in.
+func (w *World) Camera() (x, y, dirX, dirY float64) {
+	return w.player.pos.x, w.player.pos.y, w.player.dir.x, w.player.dir.y
+}
+
+// SetCamera puts the player at x, y looking along dirX, dirY.
+func (w *World) SetCamera(x, y, dirX, dirY float64) {
+	w.player.pos = vector{x: x, y: y}
+	w.player.face(vector{x: dirX, y: dirY})
+}
+
+// SetMiniMap shows or hides the mini map.
+func (w *World) SetMiniMap(show bool) {
+	w.player.showMiniMap = show
+}
+
+// armPortals stops portals the player is standing on from firing until the
+// player has stepped off them, so arriving on an exit doesn't bounce them
+// straight back.
+func (w *World) armPortals() {
+	for _, p := range w.portals {
+		p.touching = p.touches(w.player)
+	}
+}
+
+// texture returns the frame to draw now for one of a tile's textures,
+// which is the texture itself unless the tile animates it.
+func (w *World) texture(t *tile, name string) string {
+	if t == nil {
+		return name
+	}
+	if a, ok := t.animations[name]; ok {
+		return a.frame(w.clock)
+	}
+	return name
+}
+
 func (w *World) Update(delta float64) error {
+	w.clock += delta
 	hasDead := false
 	for _, e := range w.enemies {
 		e.Update(w, delta)
@@ -180,6 +365,8 @@ func (w *World) Update(delta float64) error {
 		b.Update(w, delta)
 	}
 
+	w.updateDoors(delta)
+
 	err := w.player.Update(w, delta)
 	if err != nil {
 		return err
@@ -267,8 +454,9 @@ func (w *World) ShootBullet(pos vector, dir vector, speed float64) {
 }
 
 func (w *World) AddEffect(effectType effectType, pos vector) {
-	w.effects = append(w.effects, NewEffect(effectType, pos))
-	if effectType == explosionEffectType {
+	e := NewEffect(effectType, pos)
+	w.effects = append(w.effects, e)
+	if e.explosion {
 		// do an explosion
 		for _, s := range w.scenery {
 			applyExplosionAccelerationToEntity(w, s.entity, pos)
@@ -321,14 +509,15 @@ func applyExplosionAccelerationToEntity(w *World, ent *entity, sourcePos vector)
 }
 
 func (w *World) CreateEntity(name string, pos vector) {
-	switch name {
-	case "soul":
-		w.pickups = append(w.pickups, NewPickup(soulPickupType, 1, pos))
-	case "health":
-		w.pickups = append(w.pickups, NewPickup(healthPickupType, 1, pos))
-	case "ammo":
-		w.pickups = append(w.pickups, NewPickup(ammoPickupType, 5, pos))
-	case "end":
+	if name == "end" {
 		w.portals = append(w.portals, NewPortal(pos))
+		return
+	}
+	if def, ok := entityDefs.Pickups[name]; ok {
+		amount := def.Amount
+		if def.DropAmount != 0 {
+			amount = def.DropAmount
+		}
+		w.pickups = append(w.pickups, NewPickup(def, amount, pos))
 	}
 }